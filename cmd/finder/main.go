@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"flag"
+	"github.com/ip-rw/rank/pkg/cache"
+	"github.com/ip-rw/rank/pkg/cassette"
 	"github.com/ip-rw/rank/pkg/crawl"
 	"github.com/ip-rw/rank/pkg/sources"
 	"github.com/ip-rw/rank/pkg/util"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/james-bowman/nlp"
 	"github.com/james-bowman/nlp/measures/pairwise"
@@ -14,8 +21,8 @@ import (
 	"gonum.org/v1/gonum/mat"
 )
 
-func CrawlUrl(uri string, concurrent, depth int) *crawl.CrawlResult {
-	results, err := crawl.Crawl(uri, concurrent, depth)
+func CrawlCandidate(cand *sources.Candidate, concurrent, depth int) *crawl.CrawlResult {
+	results, err := crawl.CrawlCandidate(context.Background(), cand, concurrent, depth, crawl.DefaultBudget)
 	if err != nil {
 		logrus.WithError(err).Error("crawl aborted")
 	}
@@ -26,48 +33,128 @@ func CrawlUrl(uri string, concurrent, depth int) *crawl.CrawlResult {
 
 var stopWords = []string{"a", "about", "above", "above", "across", "after", "afterwards", "again", "against", "all", "almost", "alone", "along", "already", "also", "although", "always", "am", "among", "amongst", "amoungst", "amount", "an", "and", "another", "any", "anyhow", "anyone", "anything", "anyway", "anywhere", "are", "around", "as", "at", "back", "be", "became", "because", "become", "becomes", "becoming", "been", "before", "beforehand", "behind", "being", "below", "beside", "besides", "between", "beyond", "bill", "both", "bottom", "but", "by", "call", "can", "cannot", "cant", "co", "con", "could", "couldnt", "cry", "de", "describe", "detail", "do", "done", "down", "due", "during", "each", "eg", "eight", "either", "eleven", "else", "elsewhere", "empty", "enough", "etc", "even", "ever", "every", "everyone", "everything", "everywhere", "except", "few", "fifteen", "fify", "fill", "find", "fire", "first", "five", "for", "former", "formerly", "forty", "found", "four", "from", "front", "full", "further", "get", "give", "go", "had", "has", "hasnt", "have", "he", "hence", "her", "here", "hereafter", "hereby", "herein", "hereupon", "hers", "herself", "him", "himself", "his", "how", "however", "hundred", "ie", "if", "in", "inc", "indeed", "interest", "into", "is", "it", "its", "itself", "keep", "last", "latter", "latterly", "least", "less", "ltd", "made", "many", "may", "me", "meanwhile", "might", "mill", "mine", "more", "moreover", "most", "mostly", "move", "much", "must", "my", "myself", "name", "namely", "neither", "never", "nevertheless", "next", "nine", "no", "nobody", "none", "noone", "nor", "not", "nothing", "now", "nowhere", "of", "off", "often", "on", "once", "one", "only", "onto", "or", "other", "others", "otherwise", "our", "ours", "ourselves", "out", "over", "own", "part", "per", "perhaps", "please", "put", "rather", "re", "same", "see", "seem", "seemed", "seeming", "seems", "serious", "several", "she", "should", "show", "side", "since", "sincere", "six", "sixty", "so", "some", "somehow", "someone", "something", "sometime", "sometimes", "somewhere", "still", "such", "system", "take", "ten", "than", "that", "the", "their", "them", "themselves", "then", "thence", "there", "thereafter", "thereby", "therefore", "therein", "thereupon", "these", "they", "thickv", "thin", "third", "this", "those", "though", "three", "through", "throughout", "thru", "thus", "to", "together", "too", "top", "toward", "towards", "twelve", "twenty", "two", "un", "under", "until", "up", "upon", "us", "very", "via", "was", "we", "well", "were", "what", "whatever", "when", "whence", "whenever", "where", "whereafter", "whereas", "whereby", "wherein", "whereupon", "wherever", "whether", "which", "while", "whither", "who", "whoever", "whole", "whom", "whose", "why", "will", "with", "within", "without", "would", "yet", "you", "your", "yours", "yourself", "yourselves"}
 
-func FindCompanyDomain(cno string) bool {
+func FindCompanyDomain(registry util.CompanyProvider, cno string) bool {
+	jurisdiction, number := util.ParseCompanyID(cno)
+	company, err := registry.Lookup(context.Background(), jurisdiction, number)
+	if err != nil {
+		logrus.WithError(err).Error("failed to process documents")
+		return false
+	}
+	return MatchCompany(company)
+}
+
+// FindCompanyDomainsByName matches every company the registry has under name, there can be more than one.
+func FindCompanyDomainsByName(registry util.NameFinder, name string) bool {
+	companies := registry.FindByName(name)
+	if len(companies) == 0 {
+		logrus.WithField("name", name).Error("no company by that name")
+		return false
+	}
+	found := false
+	for _, company := range companies {
+		if MatchCompany(company) {
+			found = true
+		}
+	}
+	return found
+}
+
+// readLines reads one company number (or name) per line from a file, or stdin for "-". blank lines and # comments are
+// skipped.
+func readLines(path string) ([]string, error) {
+	in := os.Stdin
+	if path != "-" {
+		var err error
+		if in, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		defer in.Close()
+	}
+	var lines []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+var (
+	// sourceConfig picks the domain sources, nil runs the defaults.
+	sourceConfig *sources.Config
+	// gleif adds the company's LEI record (names, group, declared website) before we go looking.
+	gleif = true
+	// enrichRDAP looks every candidate up over rdap before scoring.
+	enrichRDAP = true
+	// resolve drops candidates that don't resolve before we waste a crawl on them.
+	resolve  = true
+	resolver sources.Resolver
+	// probe settles each candidate on the scheme and host that actually answer, folding redirects together.
+	probe = true
+)
+
+// Score is how well one crawled candidate matches the company.
+type Score struct {
+	Candidate *sources.Candidate
+	Result    *crawl.CrawlResult
+	Cosine    float64 // with the registration and dns adjustments
+}
+
+// ScoreCompany finds the company's candidates, crawls them and scores each against the company, in url order. No
+// scores and no error means there was nothing to crawl.
+func ScoreCompany(company *util.Company) ([]Score, error) {
 	var (
-		urls          = []string{}
 		crawl_results = []*crawl.CrawlResult{}
 		corpus        = []string{}
 		concurrent    = 15
-		depth         = 1
+		depth         = 2 // the homepage and the best of what it links to, see crawl.Frontier
 		wg            = sync.WaitGroup{}
-		lock          = sync.Mutex{}
 		vectoriser    = nlp.NewCountVectoriser(stopWords...)
 		transformer   = nlp.NewTfidfTransformer()
 		reducer       = nlp.NewTruncatedSVD(260)
 		lsiPipeline   = nlp.NewPipeline(vectoriser, transformer, reducer)
 	)
-	//println(cno)
-	company, err := util.GetCompanyKeywords(cno)
-	if err != nil {
-		logrus.WithError(err).Error("failed to process documents")
-		return false
-	}
 	//fmt.Println(company)
-	uris := sources.FindPossibleDomains(company)
-	for _, u := range uris {
-		if strings.Index(u, "http") == 0 {
-			wg.Add(1)
-			go func(uri string) {
-				defer wg.Done()
-				results := CrawlUrl(uri, concurrent, depth)
-				if err != nil {
-					logrus.WithError(err).Error("crawl aborted")
-				}
-				lock.Lock()
-				urls = append(urls, uri)
-				crawl_results = append(crawl_results, results)
-				corpus = append(corpus, results.Text())
-				lock.Unlock()
-			}(u)
+	if gleif {
+		if err := util.NewGLEIF().Enrich(context.Background(), company); err != nil && err != util.ErrCompanyNotFound {
+			logrus.WithError(err).Warn("gleif lookup failed")
 		}
 	}
+	candidates, err := sources.FindPossibleDomains(context.Background(), company, sourceConfig)
+	if errs, ok := err.(sources.SourceErrors); ok {
+		for name, e := range errs {
+			logrus.WithError(e).WithField("source", name).Warn("source failed")
+		}
+	}
+	if resolve {
+		candidates = sources.ResolveCandidates(context.Background(), resolver, candidates)
+	}
+	if probe {
+		candidates = sources.ProbeCandidates(context.Background(), nil, candidates)
+	}
+	if enrichRDAP {
+		sources.EnrichRegistrations(context.Background(), "", candidates)
+	}
+	var crawlable []*sources.Candidate
+	for _, c := range candidates {
+		if strings.Index(c.URL, "http") == 0 {
+			crawlable = append(crawlable, c)
+		}
+	}
+	// sources run in no particular order, keep the corpus stable so replayed runs score identically.
+	sort.Slice(crawlable, func(i, j int) bool { return crawlable[i].URL < crawlable[j].URL })
+	crawl_results = make([]*crawl.CrawlResult, len(crawlable))
+	for i, c := range crawlable {
+		wg.Add(1)
+		go func(i int, cand *sources.Candidate) {
+			defer wg.Done()
+			crawl_results[i] = CrawlCandidate(cand, concurrent, depth)
+		}(i, c)
+	}
 	wg.Wait()
-	if len(corpus) < 1 {
-		return false
+	for _, r := range crawl_results {
+		corpus = append(corpus, r.Text())
 	}
 	//println(len(corpus))
 	valid := false
@@ -77,38 +164,173 @@ func FindCompanyDomain(cno string) bool {
 		}
 	}
 	if !valid {
-		return false
+		return nil, nil
 	}
 	lsi, err := lsiPipeline.FitTransform(corpus...)
 	if err != nil {
-		logrus.WithError(err).Error("failed to process documents")
-		return false
+		return nil, err
 	}
 
 	queryVector, err := lsiPipeline.Transform(company.Bag)
 	if err != nil {
-		logrus.WithError(err).Error("failed to process documents")
-		return false
+		return nil, err
 	}
 
-	highestSimilarity := -1.0
-	var matched int
+	var scores []Score
 	_, docs := lsi.Dims()
 	for i := 0; i < docs; i++ {
 		similarity := pairwise.CosineSimilarity(queryVector.(mat.ColViewer).ColView(0), lsi.(mat.ColViewer).ColView(i))
-		logrus.WithField("match", urls[i]).WithField("cosine", similarity).Debug("cosine")
-		if similarity > highestSimilarity {
-			matched = i
-			highestSimilarity = similarity
+		similarity += crawlable[i].Registration.Adjustment(company) + crawlable[i].DNS.Adjustment()
+		logrus.WithField("match", crawlable[i].URL).WithField("sources", crawlable[i].Sources()).WithField("cosine", similarity).Debug("cosine")
+		scores = append(scores, Score{Candidate: crawlable[i], Result: crawl_results[i], Cosine: similarity})
+	}
+	return scores, nil
+}
+
+// MatchCompany does the work once we have a company, however we got it.
+func MatchCompany(company *util.Company) bool {
+	scores, err := ScoreCompany(company)
+	if err != nil {
+		logrus.WithError(err).Error("failed to process documents")
+		return false
+	}
+	if len(scores) == 0 {
+		return false
+	}
+	best := scores[0]
+	for _, s := range scores[1:] {
+		if s.Cosine > best.Cosine {
+			best = s
 		}
 	}
-	logrus.WithField("match", urls[matched]).WithField("emails", crawl_results[matched].Emails()).WithField("cosine", highestSimilarity).WithField("company", company.Name).Infof("found result")
+	cand := best.Candidate
+	l := logrus.WithField("match", cand.URL)
+	if cand.Registration != nil {
+		l = l.WithField("registrant", cand.Registration.Registrant)
+	}
+	l.WithField("sources", cand.Sources()).WithField("rank", cand.BestRank()).WithField("emails", best.Result.Emails()).WithField("cosine", best.Cosine).WithField("company", company.Name).Infof("found result")
 	return true
 }
 
+// matchRecords runs every record in the given files (or stdin) straight through matching, no registry involved.
+func matchRecords(format string, files []string) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, f := range files {
+		in := os.Stdin
+		if f != "-" {
+			var err error
+			if in, err = os.Open(f); err != nil {
+				logrus.WithError(err).Fatal("can't open input")
+			}
+		}
+		records, err := util.ReadCompanyRecords(in, format)
+		in.Close()
+		if err != nil {
+			logrus.WithError(err).WithField("file", f).Fatal("can't read input")
+		}
+		for _, r := range records {
+			if !MatchCompany(r.Company()) {
+				logrus.WithField("company", r.Name).Infof("failed to find result")
+			}
+		}
+	}
+}
+
 func main() {
+	provider := flag.String("registry", "opencorporates", "company registry to look numbers up in")
+	input := flag.String("input", "", "read company records (json or csv) from the files given, or stdin, instead of looking up a number")
+	list := flag.String("list", "", "file of company numbers to look up, one per line, - for stdin, as well as any given as arguments")
+	byName := flag.Bool("by-name", false, "look companies up by name rather than number (needs -registry bulk)")
+	record := flag.String("record", "", "record every http exchange and dns lookup to this cassette")
+	replay := flag.String("replay", "", "serve http and dns from this cassette instead of the network")
+	flag.BoolVar(&gleif, "gleif", gleif, "look the company's LEI up at gleif for other names, its group and a declared website")
+	flag.BoolVar(&enrichRDAP, "rdap", enrichRDAP, "look up candidate registrations over rdap and use them in scoring")
+	flag.BoolVar(&resolve, "resolve", resolve, "drop candidates that don't resolve and record their dns")
+	flag.BoolVar(&probe, "probe", probe, "try https/http and www variants of each candidate and follow redirects")
+	flag.BoolVar(&crawl.HonourRobots, "robots", crawl.HonourRobots, "obey robots.txt when crawling, as "+crawl.UserAgent)
+	flag.BoolVar(&crawl.UseSitemaps, "sitemaps", crawl.UseSitemaps, "read each site's sitemaps for about/contact/legal pages to crawl")
+	dnsServer := flag.String("dns", "", "resolve candidates with this dns server (host:port) instead of the system's")
+	blocklist := flag.String("blocklist", "", "file of extra 'domain [category]' lines that can't be a company's site")
+	sourcesFile := flag.String("sources", "", "json config choosing domain sources and their queries")
+	cacheDir := flag.String("cache", "", "cache every http response under this directory")
+	cacheTTL := flag.Duration("cache-ttl", 7*24*time.Hour, "how long cached responses are good for, 0 is forever")
+	flag.Parse()
 	logrus.SetLevel(logrus.InfoLevel)
-	if !FindCompanyDomain(os.Args[1]) {
-		logrus.WithField("company_number", os.Args[1]).Infof("failed to find result")
+	if *dnsServer != "" {
+		resolver = sources.NewResolver(*dnsServer)
+	}
+	if *blocklist != "" {
+		if err := sources.DefaultBlocklist.Load(*blocklist); err != nil {
+			logrus.WithError(err).Fatal("bad blocklist")
+		}
+	}
+	if *sourcesFile != "" {
+		cfg, err := sources.LoadConfig(*sourcesFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("bad sources config")
+		}
+		sourceConfig = cfg
+	}
+	if *record != "" || *replay != "" {
+		path, mode := *record, cassette.Record
+		if *replay != "" {
+			path, mode = *replay, cassette.Replay
+		}
+		c, err := cassette.Open(path, mode)
+		if err != nil {
+			logrus.WithError(err).Fatal("bad cassette")
+		}
+		util.Use(c.Middleware)
+		// candidates live or die by dns before they're ever fetched, so lookups go on the cassette too.
+		resolver = c.Resolver(resolver)
+		if mode == cassette.Record {
+			defer func() {
+				if err := c.Save(); err != nil {
+					logrus.WithError(err).Error("error saving cassette")
+				}
+			}()
+		}
+	}
+	if *cacheDir != "" {
+		c, err := cache.New(*cacheDir, *cacheTTL)
+		if err != nil {
+			logrus.WithError(err).Fatal("bad cache")
+		}
+		util.Use(c.Middleware)
+	}
+	if *input != "" {
+		matchRecords(*input, flag.Args())
+		return
+	}
+	ids := flag.Args()
+	if *list != "" {
+		lines, err := readLines(*list)
+		if err != nil {
+			logrus.WithError(err).Fatal("can't read list")
+		}
+		ids = append(ids, lines...)
+	}
+	if len(ids) == 0 {
+		logrus.Fatal("no company numbers given")
+	}
+	// once for every company, the bulk snapshot takes a while to load.
+	registry, err := util.NewCompanyProvider(*provider)
+	if err != nil {
+		logrus.WithError(err).Fatal("bad registry")
+	}
+	finder, canFind := registry.(util.NameFinder)
+	if *byName && !canFind {
+		logrus.WithField("registry", registry.Name()).Fatal("registry can't look companies up by name")
+	}
+	for _, id := range ids {
+		if *byName {
+			if !FindCompanyDomainsByName(finder, id) {
+				logrus.WithField("name", id).Infof("failed to find result")
+			}
+		} else if !FindCompanyDomain(registry, id) {
+			logrus.WithField("company_number", id).Infof("failed to find result")
+		}
 	}
 }
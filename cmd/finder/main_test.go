@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ip-rw/rank/pkg/cassette"
+	"github.com/ip-rw/rank/pkg/sources"
+	"github.com/ip-rw/rank/pkg/util"
+)
+
+var update = flag.Bool("update", false, "re-record the testdata cassettes and scores from the stand-ins below")
+
+// fixtures are companies in testdata, each with the cassette of everything finding its site asked for and the scores
+// that came out. best is the site it should match, "" for a company with no site at all.
+var fixtures = []struct {
+	name string
+	best string
+}{
+	{"acme", "https://www.acmewidgets.co.uk"},
+	// dissolved, its old domain lapsed and went to a reseller. The name's .co.uk belongs to a spa, which still
+	// outscores the parked domain and is what we'd match, with nothing to say a dissolved company has no site.
+	{"brightwater", "https://www.brightwater.co.uk"},
+	// never had a site, every guess is nxdomain.
+	{"hollins", ""},
+}
+
+// the fixtures' sources, the guesses cut down so the cassettes stay small.
+var fixtureSources = &sources.Config{Sources: []sources.SourceConfig{
+	{Name: "TLD", Options: map[string]string{"tlds": "co.uk,com", "limit": "6"}},
+	{Name: "CertificateTransparency"},
+	{Name: "Wikidata"},
+	{Name: "GLEIF"},
+}}
+
+// Replaying a recorded company through the sources, dns, probing, rdap, the crawl and scoring has to give exactly
+// the scores it gave when it was recorded, run after run.
+func TestReplayScores(t *testing.T) {
+	sourceConfig = fixtureSources
+	defer func() { sourceConfig, resolver = nil, nil }()
+	var s *httptest.Server
+	if *update {
+		s = httptest.NewServer(standIns(t))
+		defer s.Close()
+	}
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			replay(t, f.name, f.best, s)
+		})
+	}
+}
+
+func replay(t *testing.T, name, want string, standIn *httptest.Server) {
+	mode := cassette.Replay
+	if *update {
+		mode = cassette.Record
+	}
+	c, err := cassette.Open("testdata/"+name+".cassette.json", mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	util.Use(c.Middleware)
+	defer util.ResetMiddleware()
+	resolver = c.Resolver(nil)
+	if *update {
+		// the cassette sees the real urls, only underneath them does everything go to the stand-ins.
+		util.Use(func(rt http.RoundTripper) http.RoundTripper { return reroute{standIn, rt} })
+		resolver = c.Resolver(standInDNS{})
+	}
+
+	runs := 2
+	if *update {
+		runs = 1
+	}
+	golden := "testdata/" + name + ".scores"
+	for run := 0; run < runs; run++ {
+		scores, err := ScoreCompany(fixture(t, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		for _, s := range scores {
+			fmt.Fprintf(&b, "%s\t%s\t%s\n", s.Candidate.URL, strings.Join(s.Candidate.Sources(), ","), strconv.FormatFloat(s.Cosine, 'g', -1, 64))
+		}
+		if *update {
+			if err := c.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(golden, b.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		scored, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b.Bytes(), scored) {
+			t.Errorf("run %d scored\n%s\nwant\n%s", run, b.Bytes(), scored)
+		}
+		if best := best(scores); best != want {
+			t.Errorf("run %d matched %q, want %q", run, best, want)
+		}
+	}
+}
+
+// fixture is the company as a registry gave it to us.
+func fixture(t *testing.T, name string) *util.Company {
+	b, err := ioutil.ReadFile("testdata/" + name + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c util.Company
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatalf("bad fixture %s: %v", name, err)
+	}
+	c.BuildBag()
+	return &c
+}
+
+func best(scores []Score) string {
+	if len(scores) == 0 {
+		return ""
+	}
+	b := scores[0]
+	for _, s := range scores[1:] {
+		if s.Cosine > b.Cosine {
+			b = s
+		}
+	}
+	return b.Candidate.URL
+}
+
+// reroute sends every request to the stand-in server, keeping the host it was meant for.
+type reroute struct {
+	s  *httptest.Server
+	rt http.RoundTripper
+}
+
+func (r reroute) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(r.s.URL)
+	out := req.Clone(req.Context())
+	out.URL.Scheme, out.URL.Host, out.Host = u.Scheme, u.Host, req.URL.Hostname()
+	resp, err := r.rt.RoundTrip(out)
+	if err == nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// standInDNS knows the fixture's hosts, everything else is nxdomain.
+type standInDNS struct{}
+
+var standInHosts = map[string]string{
+	"acmewidgets.co.uk": "192.0.2.10", "www.acmewidgets.co.uk": "192.0.2.10",
+	"acmewidgets.com": "192.0.2.11", "acme-widgets.com": "192.0.2.12",
+	"widgetworld.com": "198.51.100.7", "www.widgetworld.com": "198.51.100.7",
+	"brightwatertrading.co.uk": "192.0.2.20", "brightwater.co.uk": "203.0.113.5", "www.brightwater.co.uk": "203.0.113.5",
+}
+
+// parkedHosts are on a reseller's nameservers.
+var parkedHosts = map[string]bool{"acme-widgets.com": true, "brightwatertrading.co.uk": true}
+
+func (standInDNS) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if ip, ok := standInHosts[host]; ok {
+		return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (standInDNS) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if _, ok := standInHosts[name]; !ok || parkedHosts[name] {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return []*net.MX{{Host: "mail." + name + ".", Pref: 10}}, nil
+}
+
+func (standInDNS) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	if parkedHosts[name] {
+		return []*net.NS{{Host: "ns1.sedoparking.com."}, {Host: "ns2.sedoparking.com."}}, nil
+	}
+	if _, ok := standInHosts[name]; !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return []*net.NS{{Host: "ns1.example-dns.net."}}, nil
+}
+
+func (standInDNS) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return []string{"v=spf1 -all"}, nil
+}
+
+// standIns answers for every service and site the fixture company touches.
+func standIns(t *testing.T) http.Handler {
+	pages := map[string]string{
+		"www.acmewidgets.co.uk/": `<title>Acme Widgets | Precision widgets from Sheffield</title>
+			<h1>Precision widgets, made in Sheffield since 1999</h1>
+			<p>Acme Widgets Ltd designs and manufactures precision steel widgets for the automotive and aerospace trades.</p>
+			<a href="/about-us">About us</a> <a href="/contact">Contact us</a> <a href="/products">Products</a>
+			<footer>Acme Widgets Ltd, 1 Forge Lane, Sheffield S1 2AB. Registered in England and Wales no. 01234567.</footer>`,
+		"www.acmewidgets.co.uk/about-us": `<title>About Acme Widgets</title><h1>About us</h1>
+			<p>Founded by Jane Smith and Robert Jones, Acme Widgets Ltd has made widgets at Forge Lane, Sheffield for over twenty years.</p>
+			<footer>Acme Widgets Ltd, 1 Forge Lane, Sheffield S1 2AB. Registered in England and Wales no. 01234567.</footer>`,
+		"www.acmewidgets.co.uk/contact": `<title>Contact Acme Widgets</title><h1>Contact</h1>
+			<p>Acme Widgets Ltd, 1 Forge Lane, Sheffield, S1 2AB, United Kingdom. sales@acmewidgets.co.uk</p>`,
+		"www.acmewidgets.co.uk/products": `<title>Products</title><h1>Widgets</h1><p>Steel widgets, brass widgets, custom widgets.</p>`,
+		"www.widgetworld.com/": `<title>Widget World</title><h1>Novelty widgets for every occasion</h1>
+			<p>Widget World Inc ships fun widgets and gadgets across Ohio and the United States.</p>
+			<a href="/about">About Widget World</a>`,
+		"www.widgetworld.com/about": `<title>About Widget World</title><p>Widget World Inc, 500 Main Street, Columbus, Ohio.</p>`,
+		"acme-widgets.com/":         `<title>acme-widgets.com is for sale</title><p>This domain may be for sale. Buy acme-widgets.com today.</p>`,
+		"brightwatertrading.co.uk/": `<title>brightwatertrading.co.uk</title><p>brightwatertrading.co.uk is available! Make an offer today.</p>
+			<p>Related searches: trading platform, bristol business, import export</p>`,
+		"www.brightwater.co.uk/": `<title>Brightwater Spa | Cornwall</title><h1>Relax at Brightwater</h1>
+			<p>Brightwater Spa Ltd, a day spa on the harbour at St Ives, Cornwall. Treatments, pool and sauna.</p>
+			<a href="/treatments">Treatments</a> <a href="/contact">Contact</a>`,
+		"www.brightwater.co.uk/treatments": `<title>Treatments</title><p>Massage, facials and hot stone therapy.</p>`,
+		"www.brightwater.co.uk/contact":    `<title>Contact</title><p>Brightwater Spa Ltd, Harbour Road, St Ives TR26 1LP.</p>`,
+	}
+	redirects := map[string]string{
+		"acmewidgets.co.uk": "https://www.acmewidgets.co.uk/",
+		"acmewidgets.com":   "https://www.acmewidgets.co.uk/",
+		"widgetworld.com":   "https://www.widgetworld.com/",
+		"brightwater.co.uk": "https://www.brightwater.co.uk/",
+	}
+	const gleifRecord = `{"id":"213800ACMEWIDGETS0001","attributes":{"lei":"213800ACMEWIDGETS0001",
+		"entity":{"legalName":{"name":"ACME WIDGETS LIMITED"},"otherNames":[{"name":"ACME FORGE LIMITED","type":"PREVIOUS_LEGAL_NAME"}],
+		"legalAddress":{"addressLines":["1 Forge Lane"],"city":"Sheffield","country":"GB","postalCode":"S1 2AB"},
+		"jurisdiction":"GB","registeredAs":"01234567","status":"ACTIVE"},
+		"registration":{"initialRegistrationDate":"2014-02-03T00:00:00Z"}}}`
+	wdItem := func(id, label, site string) string {
+		return fmt.Sprintf(`%q:{"id":%q,"labels":{"en":{"value":%q}},"claims":{"P856":[{"mainsnak":{"snaktype":"value","datavalue":{"value":%q}},"rank":"normal"}]}}`, id, id, label, site)
+	}
+	rdap := func(registrant, created string) string {
+		return fmt.Sprintf(`{"ldhName":"x","entities":[{"roles":["registrant"],"vcardArray":["vcard",[["fn",{},"text",%q]]]}],
+			"events":[{"eventAction":"registration","eventDate":%q}]}`, registrant, created)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.Host {
+		case "api.gleif.org":
+			switch {
+			case r.URL.Path == "/api/v1/lei-records" && q.Get("filter[entity.registeredAs]") == "01234567":
+				fmt.Fprintf(w, `{"data":[%s]}`, gleifRecord)
+			case r.URL.Path == "/api/v1/lei-records", strings.HasSuffix(r.URL.Path, "/direct-children"):
+				fmt.Fprint(w, `{"data":[]}`)
+			default:
+				http.NotFound(w, r)
+			}
+			return
+		case "crt.sh":
+			switch q.Get("O") {
+			case "ACME WIDGETS LIMITED":
+				fmt.Fprint(w, `[{"id":1,"common_name":"www.acmewidgets.co.uk","name_value":"acmewidgets.co.uk\nwww.acmewidgets.co.uk"},
+					{"id":2,"common_name":"widgetworld.com","name_value":"widgetworld.com\nwww.widgetworld.com"}]`)
+			case "BRIGHTWATER TRADING LTD":
+				// from before it was dissolved.
+				fmt.Fprint(w, `[{"id":3,"common_name":"brightwatertrading.co.uk","name_value":"brightwatertrading.co.uk\nwww.brightwatertrading.co.uk","not_after":"2016-03-01T00:00:00"}]`)
+			default:
+				fmt.Fprint(w, `[]`)
+			}
+			return
+		case "www.wikidata.org":
+			switch {
+			case q.Get("action") == "wbgetentities":
+				fmt.Fprintf(w, `{"entities":{%s,%s}}`, wdItem("Q1", "Acme Widgets", "https://www.acmewidgets.co.uk/"),
+					wdItem("Q2", "Widget World", "https://www.widgetworld.com/"))
+			case q.Get("srsearch") == "haswbstatement:P2622=01234567", q.Get("srsearch") == "haswbstatement:P1278=213800ACMEWIDGETS0001":
+				fmt.Fprint(w, `{"query":{"search":[{"title":"Q1"}]}}`)
+			case strings.HasPrefix(strings.ToLower(q.Get("srsearch")), "acme widgets "):
+				fmt.Fprint(w, `{"query":{"search":[{"title":"Q1"},{"title":"Q2"}]}}`)
+			default:
+				fmt.Fprint(w, `{"query":{"search":[]}}`)
+			}
+			return
+		case "rdap.org":
+			switch strings.TrimPrefix(r.URL.Path, "/domain/") {
+			case "acmewidgets.co.uk":
+				fmt.Fprint(w, rdap("Acme Widgets Ltd", "1999-03-01T00:00:00Z"))
+			case "widgetworld.com":
+				fmt.Fprint(w, rdap("Widget World Inc", "2011-07-19T00:00:00Z"))
+			case "brightwatertrading.co.uk":
+				// picked up by a reseller once it lapsed.
+				fmt.Fprint(w, rdap("Domain Sales Ltd", "2020-01-09T00:00:00Z"))
+			case "brightwater.co.uk":
+				fmt.Fprint(w, rdap("Brightwater Spa Ltd", "2004-05-17T00:00:00Z"))
+			default:
+				http.NotFound(w, r)
+			}
+			return
+		}
+		if to, ok := redirects[r.Host]; ok {
+			http.Redirect(w, r, to, http.StatusMovedPermanently)
+			return
+		}
+		if page, ok := pages[r.Host+r.URL.Path]; ok {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<html><body>%s</body></html>", page)
+			return
+		}
+		if _, ok := standInHosts[r.Host]; !ok {
+			t.Errorf("stand-in asked for %s%s", r.Host, r.URL)
+		}
+		http.NotFound(w, r)
+	})
+}
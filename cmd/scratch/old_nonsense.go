@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"github.com/ip-rw/rank/pkg/crawl"
 	"github.com/ip-rw/rank/pkg/sources"
 	"github.com/ip-rw/rank/pkg/util"
@@ -16,7 +17,7 @@ import (
 var waitGroup = &sync.WaitGroup{}
 
 func CrawlUrl(uri string, concurrent, depth int) *crawl.CrawlResult {
-	results, err := crawl.Crawl(uri, concurrent, depth)
+	results, err := crawl.Crawl(context.Background(), uri, concurrent, depth, crawl.DefaultBudget)
 	if err != nil {
 		logrus.WithError(err).Error("crawl aborted")
 	}
@@ -40,7 +41,7 @@ func FindCompanyDomain(cno string) {
 		logrus.WithError(err).Error("failed to process documents")
 		return
 	}
-	uris := sources.FindPossibleDomains(company)
+	uris, _ := sources.FindPossibleDomains(context.Background(), company, nil)
 	weights := map[string]int{
 		sources.CleanCompanyName(company.Name):                         10,
 		sources.CleanCompanyName(company.RegisteredAddress.PostalCode): 50,
@@ -53,8 +54,8 @@ func FindCompanyDomain(cno string) {
 	//if len(company.IndustryCodes) > 0 {
 	//	fmt.Println("SEC", company.IndustryCodes[0].IndustryCode.Description)
 	//}
-	for _, u := range uris {
-		if strings.Index(u, "http") == 0 {
+	for _, c := range uris {
+		if u := c.URL; strings.Index(u, "http") == 0 {
 			wg.Add(1)
 
 			go func(comp util.Company, uri string) {
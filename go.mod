@@ -8,7 +8,7 @@ require (
 	github.com/antchfx/htmlquery v1.2.3 // indirect
 	github.com/antchfx/xmlquery v1.3.6 // indirect
 	github.com/armon/go-radix v1.0.0 // indirect
-	github.com/byung82/go-cloudflare-scraper v0.0.0-20210326023602-b801d58c4ab2 // indirect
+	github.com/byung82/go-cloudflare-scraper v0.0.0-20210326023602-b801d58c4ab2
 	github.com/go-gota/gota v0.10.1
 	github.com/gobwas/glob v0.2.3 // indirect
 	github.com/gocolly/colly v1.2.0
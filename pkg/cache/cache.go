@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Cache is a content addressed store on disk, entries live at Dir/ab/abcdef... and expire TTL after they were written.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+}
+
+func New(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir, TTL: ttl}, nil
+}
+
+// Key hashes everything that might change the answer, the url plus the method, headers and body of the request.
+func Key(method, uri string, header http.Header, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + uri + "\n"))
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.Write([]byte(name + ": " + strings.Join(header[name], ",") + "\n"))
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key)
+}
+
+func (c *Cache) Get(key string) ([]byte, bool) {
+	p := c.path(key)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, false
+	}
+	if c.TTL > 0 && time.Since(fi.ModTime()) > c.TTL {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+func (c *Cache) Put(key string, data []byte) error {
+	p := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Transport answers GET and HEAD requests from the cache when it can, and stores whatever Base says when it can't.
+// Server errors and rate limiting are never stored.
+type Transport struct {
+	Cache *Cache
+	Base  http.RoundTripper
+}
+
+// Middleware wraps rt in a caching Transport, it fits util.Use.
+func (c *Cache) Middleware(rt http.RoundTripper) http.RoundTripper {
+	return &Transport{Cache: c, Base: rt}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return base.RoundTrip(req)
+	}
+	key := Key(req.Method, req.URL.String(), req.Header, nil)
+	l := logrus.WithField("url", req.URL.String())
+	if b, ok := t.Cache.Get(key); ok {
+		if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), req); err == nil {
+			l.Debug("cache hit")
+			return resp, nil
+		}
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return resp, nil
+	}
+	b, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Cache.Put(key, b); err != nil {
+		l.WithError(err).Warn("error writing cache")
+	}
+	return resp, nil
+}
@@ -0,0 +1,198 @@
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// Record passes every request through and remembers the exchange.
+	Record Mode = iota
+	// Replay never touches the network, requests without a recording fail.
+	Replay
+)
+
+var ErrNoRecording = errors.New("cassette: no recorded interaction")
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is a set of recorded http exchanges, and the dns lookups made alongside them. Requests are matched on method, url and body, identical requests
+// are replayed in the order they were recorded (the last one repeats), so concurrent callers can't shuffle them.
+type Cassette struct {
+	Path         string         `json:"-"`
+	Mode         Mode           `json:"-"`
+	Interactions []*Interaction `json:"interactions"`
+	Lookups      []*Lookup      `json:"lookups,omitempty"`
+
+	lock    sync.Mutex
+	byKey   map[string][]*Interaction
+	served  map[string]int
+	lookups map[string]*Lookup
+}
+
+// New starts an empty cassette that will be written to path.
+func New(path string, mode Mode) *Cassette {
+	return &Cassette{Path: path, Mode: mode, byKey: map[string][]*Interaction{}, served: map[string]int{},
+		lookups: map[string]*Lookup{}}
+}
+
+// Load reads a cassette from disk.
+func Load(path string, mode Mode) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := New(path, mode)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	for _, i := range c.Interactions {
+		k := key(i.Request.Method, i.Request.URL, i.Request.Body)
+		c.byKey[k] = append(c.byKey[k], i)
+	}
+	for _, l := range c.Lookups {
+		c.lookups[lookupKey(l.Kind, l.Name)] = l
+	}
+	return c, nil
+}
+
+// Open is Load for Replay and New for Record.
+func Open(path string, mode Mode) (*Cassette, error) {
+	if mode == Replay {
+		return Load(path, mode)
+	}
+	return New(path, mode), nil
+}
+
+func key(method, uri string, body []byte) string {
+	h := sha256.Sum256(body)
+	return method + " " + uri + " " + hex.EncodeToString(h[:8])
+}
+
+// Save writes the cassette out, sorted so re-recording the same run gives the same file.
+func (c *Cassette) Save() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sort.SliceStable(c.Interactions, func(i, j int) bool {
+		a, b := c.Interactions[i].Request, c.Interactions[j].Request
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		return a.Method < b.Method
+	})
+	sort.SliceStable(c.Lookups, func(i, j int) bool {
+		return lookupKey(c.Lookups[i].Kind, c.Lookups[i].Name) < lookupKey(c.Lookups[j].Kind, c.Lookups[j].Name)
+	})
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Path, b, 0644)
+}
+
+// Middleware wraps rt so every request goes through the cassette, it fits util.Use.
+func (c *Cassette) Middleware(rt http.RoundTripper) http.RoundTripper {
+	return &Transport{Cassette: c, Base: rt}
+}
+
+type Transport struct {
+	Cassette *Cassette
+	Base     http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	c := t.Cassette
+	k := key(req.Method, req.URL.String(), body)
+	if c.Mode == Replay {
+		c.lock.Lock()
+		recorded := c.byKey[k]
+		n := c.served[k]
+		c.served[k]++
+		c.lock.Unlock()
+		if len(recorded) == 0 {
+			return nil, fmt.Errorf("%w for %s %s", ErrNoRecording, req.Method, req.URL)
+		}
+		if n >= len(recorded) {
+			n = len(recorded) - 1
+		}
+		return recorded[n].Response.toHTTP(req), nil
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	i := &Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body},
+		Response: Response{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header.Clone(), Body: respBody},
+	}
+	// never write credentials to disk.
+	for _, h := range []string{"Authorization", "Cookie", "X-Subscription-Token"} {
+		i.Request.Header.Del(h)
+	}
+	c.lock.Lock()
+	c.Interactions = append(c.Interactions, i)
+	c.byKey[k] = append(c.byKey[k], i)
+	c.lock.Unlock()
+	return resp, nil
+}
+
+func (r *Response) toHTTP(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        r.Status,
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,144 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Lookup is one recorded dns question and its answer.
+type Lookup struct {
+	Kind     string   `json:"kind"` // ip, mx, ns or txt
+	Name     string   `json:"name"`
+	Values   []string `json:"values,omitempty"`
+	NotFound bool     `json:"not_found,omitempty"`
+	Err      string   `json:"error,omitempty"`
+}
+
+// resolver is sources.Resolver, spelt out here so the cassette doesn't depend on sources.
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Resolver records dns lookups into the cassette, or answers them from it, the way Transport does http. Base is
+// what's asked when recording, net.DefaultResolver if nil.
+type Resolver struct {
+	Cassette *Cassette
+	Base     resolver
+}
+
+// Resolver wraps base so lookups go through the cassette too.
+func (c *Cassette) Resolver(base resolver) *Resolver {
+	return &Resolver{Cassette: c, Base: base}
+}
+
+func lookupKey(kind, name string) string {
+	return kind + " " + strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// lookup answers from the cassette when replaying, otherwise asks ask and records what it said.
+func (r *Resolver) lookup(kind, name string, ask func(resolver) ([]string, error)) ([]string, error) {
+	c := r.Cassette
+	k := lookupKey(kind, name)
+	if c.Mode == Replay {
+		c.lock.Lock()
+		l := c.lookups[k]
+		c.lock.Unlock()
+		switch {
+		case l == nil:
+			return nil, fmt.Errorf("%w for %s %s", ErrNoRecording, kind, name)
+		case l.NotFound:
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		case l.Err != "":
+			return nil, &net.DNSError{Err: l.Err, Name: name}
+		}
+		return l.Values, nil
+	}
+
+	base := r.Base
+	if base == nil {
+		base = net.DefaultResolver
+	}
+	values, err := ask(base)
+	l := &Lookup{Kind: kind, Name: strings.ToLower(strings.TrimSuffix(name, ".")), Values: values}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		l.NotFound = true
+	} else if err != nil {
+		l.Err = err.Error()
+	}
+	c.lock.Lock()
+	if _, ok := c.lookups[k]; !ok {
+		c.Lookups = append(c.Lookups, l)
+	}
+	c.lookups[k] = l
+	c.lock.Unlock()
+	return values, err
+}
+
+func (r *Resolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	values, err := r.lookup("ip", host, func(b resolver) ([]string, error) {
+		ips, err := b.LookupIPAddr(ctx, host)
+		var out []string
+		for _, ip := range ips {
+			out = append(out, ip.String())
+		}
+		return out, err
+	})
+	var ips []net.IPAddr
+	for _, v := range values {
+		if ip := net.ParseIP(v); ip != nil {
+			ips = append(ips, net.IPAddr{IP: ip})
+		}
+	}
+	return ips, err
+}
+
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	values, err := r.lookup("mx", name, func(b resolver) ([]string, error) {
+		mx, err := b.LookupMX(ctx, name)
+		var out []string
+		for _, m := range mx {
+			out = append(out, strconv.Itoa(int(m.Pref))+" "+m.Host)
+		}
+		return out, err
+	})
+	var mx []*net.MX
+	for _, v := range values {
+		parts := strings.SplitN(v, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		pref, _ := strconv.Atoi(parts[0])
+		mx = append(mx, &net.MX{Host: parts[1], Pref: uint16(pref)})
+	}
+	return mx, err
+}
+
+func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	values, err := r.lookup("ns", name, func(b resolver) ([]string, error) {
+		ns, err := b.LookupNS(ctx, name)
+		var out []string
+		for _, n := range ns {
+			out = append(out, n.Host)
+		}
+		return out, err
+	})
+	var ns []*net.NS
+	for _, v := range values {
+		ns = append(ns, &net.NS{Host: v})
+	}
+	return ns, err
+}
+
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r.lookup("txt", name, func(b resolver) ([]string, error) {
+		return b.LookupTXT(ctx, name)
+	})
+}
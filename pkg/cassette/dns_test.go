@@ -0,0 +1,79 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type fakeResolver struct{ asked int }
+
+func (f *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	f.asked++
+	if host == "missing.com" {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("2001:db8::1")}}, nil
+}
+
+func (f *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	f.asked++
+	return []*net.MX{{Host: "mx.example.com.", Pref: 10}}, nil
+}
+
+func (f *fakeResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	f.asked++
+	return []*net.NS{{Host: "ns1.example.com."}}, nil
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	f.asked++
+	return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+}
+
+func TestResolverReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dns.json")
+	rec := New(path, Record)
+	r := rec.Resolver(&fakeResolver{})
+	ips, _ := r.LookupIPAddr(ctx, "example.com")
+	mx, _ := r.LookupMX(ctx, "example.com")
+	ns, _ := r.LookupNS(ctx, "example.com")
+	r.LookupTXT(ctx, "example.com")
+	r.LookupIPAddr(ctx, "missing.com")
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	play, err := Load(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &fakeResolver{}
+	r = play.Resolver(base)
+	if got, err := r.LookupIPAddr(ctx, "EXAMPLE.com."); err != nil || !reflect.DeepEqual(got, ips) {
+		t.Errorf("ips = %v, %v, want %v", got, err, ips)
+	}
+	if got, err := r.LookupMX(ctx, "example.com"); err != nil || !reflect.DeepEqual(got, mx) {
+		t.Errorf("mx = %v, %v, want %v", got, err, mx)
+	}
+	if got, err := r.LookupNS(ctx, "example.com"); err != nil || !reflect.DeepEqual(got, ns) {
+		t.Errorf("ns = %v, %v, want %v", got, err, ns)
+	}
+	if _, err := r.LookupTXT(ctx, "example.com"); err == nil {
+		t.Error("txt should fail as it did when recorded")
+	}
+	var dnsErr *net.DNSError
+	if _, err := r.LookupIPAddr(ctx, "missing.com"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("missing.com err = %v, want not found", err)
+	}
+	if _, err := r.LookupIPAddr(ctx, "other.com"); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("other.com err = %v, want ErrNoRecording", err)
+	}
+	if base.asked != 0 {
+		t.Errorf("replay asked the real resolver %d times", base.asked)
+	}
+}
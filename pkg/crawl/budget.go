@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Budget is how much of a site we're prepared to crawl. A zero field means no limit.
+type Budget struct {
+	MaxPages     int
+	MaxPageBytes int   // longer bodies are cut short
+	MaxBytes     int64 // across every response
+	MaxDuration  time.Duration
+	MaxErrors    int
+}
+
+// DefaultBudget is what the finder crawls each candidate with.
+var DefaultBudget = Budget{
+	MaxPages:     20,
+	MaxPageBytes: 2 << 20,
+	MaxBytes:     20 << 20,
+	MaxDuration:  time.Minute,
+	MaxErrors:    10,
+}
+
+// the limit that stopped a crawl, CrawlResult.Limit.
+const (
+	LimitPages    = "pages"
+	LimitBytes    = "bytes"
+	LimitDuration = "duration"
+	LimitErrors   = "errors"
+	LimitCanceled = "canceled"
+)
+
+// exceeded is the first limit the crawl has reached, "" if none.
+func (b Budget) exceeded(pages, errors int, bytes int64) string {
+	switch {
+	case b.MaxPages > 0 && pages >= b.MaxPages:
+		return LimitPages
+	case b.MaxErrors > 0 && errors >= b.MaxErrors:
+		return LimitErrors
+	case b.MaxBytes > 0 && bytes >= b.MaxBytes:
+		return LimitBytes
+	}
+	return ""
+}
+
+// ctxTransport ties every request the crawler makes to the crawl's context, so a deadline or cancel stops requests
+// already in flight rather than waiting on them.
+type ctxTransport struct {
+	c  *SiteCrawler
+	rt http.RoundTripper
+}
+
+func (t ctxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := t.c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return t.rt.RoundTrip(req.WithContext(ctx))
+}
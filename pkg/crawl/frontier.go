@@ -0,0 +1,147 @@
+package crawl
+
+import (
+	"container/heap"
+	"github.com/gocolly/colly"
+	"github.com/ip-rw/rank/pkg/sources"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// PriorityWords are what links to the pages worth having look like, company numbers and registered addresses are
+// almost always on one of them. Matched against the url path and the anchor text.
+var PriorityWords = map[string]float64{
+	"about":               3,
+	"contact":             3,
+	"company-information": 3,
+	"impressum":           3,
+	"legal":               2,
+	"terms":               2,
+	"privacy":             2,
+	"who-we-are":          2,
+	"imprint":             2,
+	"company":             1,
+	"cookie":              1,
+}
+
+// Link is a url waiting in the frontier.
+type Link struct {
+	URL    string
+	Anchor string
+	Score  float64
+	Depth  int            // the page's, once visited
+	from   *colly.Request // visiting through the page it was found on keeps colly's depth right
+}
+
+// Frontier holds the links found so far on one site, best first, and remembers every url it's been given.
+type Frontier struct {
+	sync.Mutex
+	Domain string // registrable domain of the site, links elsewhere are dropped
+	links  linkHeap
+	seen   map[string]bool
+}
+
+func NewFrontier(domain string) *Frontier {
+	return &Frontier{Domain: domain, seen: map[string]bool{}}
+}
+
+// ScoreLink rates a link by its path and anchor text. The frontier breaks ties on depth then url, never on the order
+// responses happened to come back in, so the same site is always crawled the same way.
+func ScoreLink(u *url.URL, anchor string) float64 {
+	p := strings.ToLower(u.Path)
+	a := strings.ToLower(anchor)
+	score := 0.0
+	for word, weight := range PriorityWords {
+		if strings.Contains(p, word) {
+			score += weight
+		}
+		// anchors say "about us" and "contact us" rather than about-us.
+		if strings.Contains(a, strings.Replace(word, "-", " ", -1)) {
+			score += weight / 2
+		}
+	}
+	// the deeper the path the less likely it's a page about the company rather than a post.
+	return score - 0.1*float64(strings.Count(strings.Trim(p, "/"), "/"))
+}
+
+// Push adds a link found on from, unless it's been seen before or is off the site.
+func (f *Frontier) Push(link, anchor string, from *colly.Request) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if f.Domain != "" && sources.RegistrableDomain(u.Hostname()) != f.Domain {
+		return false
+	}
+	f.Lock()
+	defer f.Unlock()
+	key := frontierKey(u)
+	if f.seen[key] {
+		return false
+	}
+	f.seen[key] = true
+	depth := 1
+	if from != nil {
+		depth = from.Depth + 1
+	}
+	heap.Push(&f.links, &Link{URL: key, Anchor: strings.TrimSpace(anchor), Score: ScoreLink(u, anchor), Depth: depth, from: from})
+	return true
+}
+
+// Seen marks a url we're visiting some other way, so it won't be queued again.
+func (f *Frontier) Seen(link string) {
+	if u, err := url.Parse(link); err == nil {
+		f.Lock()
+		f.seen[frontierKey(u)] = true
+		f.Unlock()
+	}
+}
+
+// frontierKey is u without its fragment, and with "/" for an empty path so http://host and http://host/ are one page.
+func frontierKey(u *url.URL) string {
+	k := *u
+	k.Fragment = ""
+	if k.Path == "" && k.Opaque == "" {
+		k.Path = "/"
+	}
+	return k.String()
+}
+
+// Pop takes up to n of the best links.
+func (f *Frontier) Pop(n int) []*Link {
+	f.Lock()
+	defer f.Unlock()
+	var out []*Link
+	for len(out) < n && f.links.Len() > 0 {
+		out = append(out, heap.Pop(&f.links).(*Link))
+	}
+	return out
+}
+
+func (f *Frontier) Len() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.links.Len()
+}
+
+type linkHeap []*Link
+
+func (h linkHeap) Len() int { return len(h) }
+func (h linkHeap) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score > h[j].Score
+	}
+	if h[i].Depth != h[j].Depth {
+		return h[i].Depth < h[j].Depth
+	}
+	return h[i].URL < h[j].URL
+}
+func (h linkHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *linkHeap) Push(x interface{}) { *h = append(*h, x.(*Link)) }
+func (h *linkHeap) Pop() interface{} {
+	old := *h
+	l := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return l
+}
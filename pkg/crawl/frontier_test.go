@@ -0,0 +1,55 @@
+package crawl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gocolly/colly"
+)
+
+func TestFrontierKey(t *testing.T) {
+	f := NewFrontier("example.com")
+	f.Seen("http://example.com")
+	for _, l := range []string{"http://example.com/", "http://example.com/#top", "http://www.example.com/about"} {
+		f.Push(l, "", nil)
+	}
+	if f.Push("http://www.example.com/about#team", "", nil) || f.Push("http://other.com/about", "", nil) {
+		t.Error("pushed a link twice or off the site")
+	}
+	if links := f.Pop(10); len(links) != 1 || links[0].URL != "http://www.example.com/about" {
+		t.Errorf("got %v, want just the about page", links)
+	}
+}
+
+// async responses push links in any order, what comes out mustn't depend on it.
+func TestFrontierOrder(t *testing.T) {
+	home := &colly.Request{Depth: 1}
+	deep := &colly.Request{Depth: 2}
+	pushes := []func(f *Frontier){
+		func(f *Frontier) { f.Push("http://example.com/contact", "", deep) },
+		func(f *Frontier) { f.Push("http://example.com/contact-us", "", home) },
+		func(f *Frontier) { f.Push("http://example.com/about", "", home) },
+		func(f *Frontier) { f.Push("http://example.com/blog", "", home) },
+		func(f *Frontier) { f.Push("http://example.com/news", "", home) },
+	}
+	want := []string{
+		"http://example.com/about",
+		"http://example.com/contact-us",
+		"http://example.com/contact",
+		"http://example.com/blog",
+		"http://example.com/news",
+	}
+	for _, order := range [][]int{{0, 1, 2, 3, 4}, {4, 3, 2, 1, 0}, {2, 4, 0, 3, 1}} {
+		f := NewFrontier("")
+		for _, i := range order {
+			pushes[i](f)
+		}
+		var got []string
+		for _, l := range f.Pop(10) {
+			got = append(got, l.URL)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pushed in order %v, got %v, want %v", order, got, want)
+		}
+	}
+}
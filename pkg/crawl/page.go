@@ -0,0 +1,95 @@
+package crawl
+
+import (
+	"bytes"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ip-rw/rank/pkg/sources"
+	"jaytaylor.com/html2text"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Page is everything we kept from one fetched url.
+type Page struct {
+	URL         string // where we ended up, after redirects
+	Status      int
+	Title       string
+	Description string   // <meta name="description">
+	Headings    []string // h1-h3, in document order
+	Footer      string   // text of <footer>, where company numbers and addresses tend to live
+	Text        string   // visible text of the whole page
+	Links       []string // every link on the page, absolute
+	Depth       int      // 1 for the page we started on
+	Fetched     time.Time
+	Elapsed     time.Duration // from request to response
+}
+
+// NewPage parses an html body fetched from uri.
+func NewPage(uri *url.URL, status int, body []byte) (*Page, error) {
+	p := &Page{URL: uri.String(), Status: status, Fetched: time.Now()}
+	text, err := html2text.FromReader(bytes.NewReader(body), html2text.Options{OmitLinks: true, PrettyTables: false})
+	if err != nil {
+		return nil, err
+	}
+	p.Text = text
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	p.Title = squash(doc.Find("title").First().Text())
+	doc.Find("meta[name]").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if name, _ := s.Attr("name"); strings.EqualFold(name, "description") {
+			p.Description = squash(s.AttrOr("content", ""))
+			return false
+		}
+		return true
+	})
+	doc.Find("h1, h2, h3").Each(func(i int, s *goquery.Selection) {
+		if h := squash(s.Text()); h != "" {
+			p.Headings = append(p.Headings, h)
+		}
+	})
+	p.Footer = squash(doc.Find("footer").Text())
+	base := uri
+	if href, ok := doc.Find("base[href]").Attr("href"); ok {
+		if b, err := uri.Parse(href); err == nil {
+			base = b
+		}
+	}
+	seen := map[string]bool{}
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		u.Fragment = ""
+		if l := u.String(); !seen[l] {
+			seen[l] = true
+			p.Links = append(p.Links, l)
+		}
+	})
+	return p, nil
+}
+
+// Outbound are the links off the page's own registrable domain.
+func (p *Page) Outbound() []string {
+	domain := sources.RegistrableDomain(p.URL)
+	var out []string
+	for _, l := range p.Links {
+		if sources.RegistrableDomain(l) != domain {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Words is the page's text the way the corpus wants it.
+func (p *Page) Words() string {
+	return sources.CleanCompanyName(p.Text)
+}
+
+func squash(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -1,17 +1,18 @@
 package crawl
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"github.com/gocolly/colly"
 	"github.com/ip-rw/rank/pkg/sources"
+	"github.com/ip-rw/rank/pkg/util"
 	cregex "github.com/mingrammer/commonregex"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/publicsuffix"
-	"jaytaylor.com/html2text"
 	"mime"
 	"path"
 	_ "regexp"
+	"sort"
 	"time"
 
 	"net/http"
@@ -22,15 +23,143 @@ import (
 
 type SiteCrawler struct {
 	*colly.Collector
-	Results *CrawlResult
-	Errors int
+	Results   *CrawlResult
+	Frontier  *Frontier
+	Budget    Budget
+	Sitemaps  bool // seed the frontier from the site's sitemaps
+	client    *http.Client
+	transport *http.Transport
+	ctx       context.Context
+	seed      *colly.Request // the first page we asked for, sitemap pages are visited as if it linked to them
+	seedOnce  sync.Once
 }
 
+var (
+	// HonourRobots makes new crawlers obey robots.txt, as UserAgent rather than pretending to be googlebot.
+	HonourRobots = false
+	// UseSitemaps makes new crawlers look for sitemaps.
+	UseSitemaps = true
+	// UserAgent is who we say we are when we're being honest about it.
+	UserAgent = "rank-finder/1.0 (+https://github.com/ip-rw/rank)"
+)
+
+// CrawlResult is filled in from colly's goroutines, so while the crawl is running go through its methods, which hold
+// the lock. Once Crawl has returned the fields are ours to read.
 type CrawlResult struct {
 	sync.Mutex
-	Scraped []*url.URL
-	text    []string
-	Email   sync.Map
+	Candidate *sources.Candidate
+	Scraped   []*url.URL
+	Pages     []*Page
+	Errors    int
+	Requests  int    // started, whether or not they came back as a page
+	Bytes     int64  // of every response body
+	Limit     string // the budget limit that stopped the crawl, "" if we ran out of site first
+	Email     sync.Map
+	started   sync.Map // request ID to when it went out, then how long it took, until the page is parsed
+}
+
+// AddPage records a page fetched from u.
+func (cr *CrawlResult) AddPage(u *url.URL, p *Page) {
+	cr.Lock()
+	defer cr.Unlock()
+	cr.Scraped = append(cr.Scraped, u)
+	cr.Pages = append(cr.Pages, p)
+}
+
+// AddError counts a failed request.
+func (cr *CrawlResult) AddError() {
+	cr.Lock()
+	defer cr.Unlock()
+	cr.Errors++
+}
+
+// AddBytes counts a response body.
+func (cr *CrawlResult) AddBytes(n int) {
+	cr.Lock()
+	defer cr.Unlock()
+	cr.Bytes += int64(n)
+}
+
+// Counts is how many pages and errors we've had so far.
+func (cr *CrawlResult) Counts() (pages, errors int) {
+	cr.Lock()
+	defer cr.Unlock()
+	return len(cr.Scraped), cr.Errors
+}
+
+// start counts a request against the budget, false (and Limit set) if there's nothing left of it.
+func (cr *CrawlResult) start(b Budget) bool {
+	cr.Lock()
+	defer cr.Unlock()
+	if cr.Limit != "" {
+		return false
+	}
+	if cr.Limit = b.exceeded(cr.Requests, cr.Errors, cr.Bytes); cr.Limit != "" {
+		return false
+	}
+	cr.Requests++
+	return true
+}
+
+// began notes when r went out. Not in r.Ctx, the requests queued from one page all share that.
+func (cr *CrawlResult) began(r *colly.Request) {
+	cr.started.Store(r.ID, time.Now())
+}
+
+// arrived stops r's clock, parsing the page shouldn't count.
+func (cr *CrawlResult) arrived(r *colly.Request) {
+	if start, ok := cr.started.Load(r.ID); ok {
+		if t, ok := start.(time.Time); ok {
+			cr.started.Store(r.ID, time.Since(t))
+		}
+	}
+}
+
+// took is how long r was out, forgetting it.
+func (cr *CrawlResult) took(r *colly.Request) (time.Duration, bool) {
+	v, ok := cr.started.LoadAndDelete(r.ID)
+	switch v := v.(type) {
+	case time.Duration:
+		return v, ok
+	case time.Time:
+		return time.Since(v), ok
+	}
+	return 0, false
+}
+
+// allowance is how much more of a response the budget lets us read, up to max, nothing once a limit's been reached.
+func (cr *CrawlResult) allowance(b Budget, max int64) int64 {
+	cr.Lock()
+	defer cr.Unlock()
+	if cr.Limit != "" || b.exceeded(cr.Requests, cr.Errors, cr.Bytes) != "" {
+		return 0
+	}
+	if b.MaxBytes > 0 && b.MaxBytes-cr.Bytes < max {
+		return b.MaxBytes - cr.Bytes
+	}
+	return max
+}
+
+// remaining is how many more requests the budget allows, -1 for no limit.
+func (cr *CrawlResult) remaining(b Budget) int {
+	cr.Lock()
+	defer cr.Unlock()
+	if cr.Limit != "" {
+		return 0
+	}
+	if b.MaxPages <= 0 {
+		return -1
+	}
+	return b.MaxPages - cr.Requests
+}
+
+// stop records why the crawl ended, the first reason sticks.
+func (cr *CrawlResult) stop(limit string) {
+	cr.Lock()
+	defer cr.Unlock()
+	if cr.Limit == "" {
+		cr.Limit = limit
+	}
 }
 
 func (cr *CrawlResult)Emails() []string {
@@ -42,55 +171,64 @@ func (cr *CrawlResult)Emails() []string {
 	return emails
 }
 
+// Text is every page's words, what goes in the corpus. Pages go in url order rather than the order they came back
+// in, so the same crawl always makes the same corpus.
 func (cr *CrawlResult)Text() string {
-	return strings.Join(cr.text, "\n")
+	cr.Lock()
+	defer cr.Unlock()
+	pages := append([]*Page{}, cr.Pages...)
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+	words := make([]string, 0, len(pages))
+	for _, p := range pages {
+		words = append(words, p.Words())
+	}
+	return strings.Join(words, "\n")
 }
 
 func NewCrawlResults() *CrawlResult {
 	return &CrawlResult{
 		Mutex:   sync.Mutex{},
 		Scraped: []*url.URL{},
-		text:    []string{},
+		Pages:   []*Page{},
 		Email:   sync.Map{},
 	}
 }
 
-func ParseAhref(e *colly.HTMLElement) {
+// ParseAhref queues a link in the frontier rather than following it there and then, so the best pages go first.
+func ParseAhref(e *colly.HTMLElement, f *Frontier, maxDepth int) {
 	link := e.Attr("href")
 	abs := e.Request.AbsoluteURL(link)
-	if len(abs) > 1 {
-		e.Request.Visit(abs)
+	if len(abs) > 1 && (maxDepth <= 0 || e.Request.Depth < maxDepth) {
+		f.Push(abs, e.Text, e.Request)
 	}
 }
 
 func ParseResponse(response *colly.Response, c *CrawlResult) {
 	l := logrus.WithField("url", response.Request.URL)
+	elapsed, _ := c.took(response.Request)
 	if strings.Index(http.DetectContentType(response.Body), "text/") != 0 {
 		l.Debug("not html, skipping")
 		return
 	}
 
-	text, err := html2text.FromReader(bytes.NewReader(response.Body), html2text.Options{OmitLinks: true, PrettyTables: false})
+	page, err := NewPage(response.Request.URL, response.StatusCode, response.Body)
 	if err != nil {
-		l.WithError(err).Error("error stripping html")
+		l.WithError(err).Error("error parsing html")
 		return
 	}
+	page.Depth = response.Request.Depth
+	page.Elapsed = elapsed
 
 	// Add page to history
-	c.Scraped = append(c.Scraped, response.Request.URL)
+	c.AddPage(response.Request.URL, page)
 
 	// Find e-mails
-	emails := cregex.Emails(text)
+	emails := cregex.Emails(page.Text)
 	if len(emails) > 0 {
 		for _, e := range emails {
 			c.Email.Store(e, 0)
 		}
 	}
-
-	for _, w := range strings.Split(sources.CleanCompanyName(text), " ") {
-		c.text = append(c.text, w)
-		//c.text = util.AppendUniq(c.text, w)
-	}
 	l.Debug("finished")
 }
 
@@ -101,8 +239,12 @@ func NewSiteCrawler(depth int) *SiteCrawler {
 			colly.Async(true),
 			colly.UserAgent("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"),
 		),
+		Frontier: NewFrontier(""),
+		Budget:   DefaultBudget,
+		Sitemaps: UseSitemaps,
+		ctx:      context.Background(),
 	}
-	c.WithTransport(&http.Transport{
+	c.transport = &http.Transport{
 		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
 		TLSHandshakeTimeout:   3 * time.Second,
 //		MaxIdleConns:          100,
@@ -111,52 +253,173 @@ func NewSiteCrawler(depth int) *SiteCrawler {
 		IdleConnTimeout:       3 * time.Second,
 		ResponseHeaderTimeout: 5 * time.Second,
 //		ForceAttemptHTTP2:     false,
-	})
-	c.IgnoreRobotsTxt = true
+	}
+	transport := ctxTransport{c, util.WrapTransport(c.transport)}
+	c.WithTransport(transport)
+	c.client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	c.IgnoreRobotsTxt = !HonourRobots
+	if HonourRobots {
+		c.UserAgent = UserAgent
+	}
 	c.CheckHead = false
 	//c.SetRedirectHandler(func(req *http.Request, via []*http.Request) error {
 	//	logrus.Println(via[len(via)-1].URL.String(), " redirected to ", req.URL.String())
 	//	return nil
 	//})
 	c.OnError(func(response *colly.Response, e error) {
-		c.Errors += 1
+		c.Results.AddError()
+		c.Results.took(response.Request)
 		logrus.WithError(e).WithField("url", response.Request.URL).Debug("request error")
 	})
 	c.OnRequest(func(request *colly.Request) {
-		if len(c.Results.Scraped) > 50 && c.Errors > 10 {
-			request.Abort()
+		if request.Depth == 1 {
+			c.seedOnce.Do(func() { c.seed = request })
 		}
 		if t := mime.TypeByExtension(path.Ext(request.URL.Path)); t != "" && strings.Index(t, "text/") != 0 {
 			logrus.WithField("url", request.URL).Debug("mime looks binary")
 			request.Abort()
+			return
+		}
+		if c.ctx.Err() != nil || !c.Results.start(c.Budget) {
+			request.Abort()
+			return
 		}
+		c.Results.began(request)
+	})
+	c.OnResponse(func(response *colly.Response) {
+		c.Results.arrived(response.Request)
+		c.Results.AddBytes(len(response.Body))
 	})
 	c.OnScraped(func(response *colly.Response) {
 		ParseResponse(response, c.Results)
 	})
 	c.OnHTML("a[href]", func(element *colly.HTMLElement) {
-		ParseAhref(element)
+		ParseAhref(element, c.Frontier, c.MaxDepth)
 	})
 
 	return c
 }
 
-func Crawl(uri string, concurrent int, depth int) (*CrawlResult, error) {
+// Crawl crawls the site at uri, starting from any seeds (deep links on the same site) as well as uri itself, until
+// the budget or the site runs out or ctx is done. Whichever limit stopped it is in CrawlResult.Limit. Nothing of the
+// crawl is left running once it returns.
+func Crawl(ctx context.Context, uri string, concurrent int, depth int, budget Budget, seeds ...string) (*CrawlResult, error) {
 	c := NewSiteCrawler(depth)
 	c.Results = NewCrawlResults()
+	c.Budget = budget
+	c.MaxBodySize = budget.MaxPageBytes
 	u, err := url.Parse(uri)
 	if err != nil {
 		return c.Results, err
 	}
+	var cancel context.CancelFunc
+	if budget.MaxDuration > 0 {
+		c.ctx, cancel = context.WithTimeout(ctx, budget.MaxDuration)
+	} else {
+		c.ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	defer c.transport.CloseIdleConnections()
 	c.AllowSubdomains(u, concurrent)
-	c.Visit(u.String())
-	if err != nil {
-		return c.Results, err
+	c.Frontier.Domain = sources.RegistrableDomain(u.Hostname())
+	// the sitemap is read alongside the crawl. found is only ever the goroutine's, sitemap is the loop's and goes nil
+	// once we've stopped waiting on it. Whatever happens, the reader is cancelled and waited for before we return, so
+	// it can't count bytes into results we've handed back.
+	var (
+		found   = make(chan []string, 1)
+		sitemap = found
+		read    = make(chan struct{})
+	)
+	go func() {
+		defer close(read)
+		if !c.Sitemaps {
+			found <- nil
+			return
+		}
+		found <- sitemaps(c.ctx, c.client, u, MaxSitemapURLs, c.Results, c.Budget)
+	}()
+	defer func() {
+		cancel()
+		<-read
+	}()
+	for _, start := range append([]string{u.String()}, seeds...) {
+		c.Frontier.Seen(start)
+		c.Visit(start)
 	}
 	c.Wait()
+	// then round after round of the best links found so far, as many at a time as we fetch in parallel.
+	for c.ctx.Err() == nil {
+		// the sitemap joins in whenever it turns up, a slow one mustn't hold up the links we already have.
+		select {
+		case pages := <-sitemap:
+			c.queueSitemap(pages)
+			sitemap = nil
+		default:
+		}
+		n := c.Results.remaining(c.Budget)
+		if n <= 0 && n != -1 {
+			if c.Frontier.Len() > 0 {
+				c.Results.stop(LimitPages)
+			}
+			break
+		}
+		if n < 0 || n > concurrent {
+			n = concurrent
+		}
+		if n < 1 {
+			n = 1
+		}
+		links := c.Frontier.Pop(n)
+		if len(links) == 0 {
+			if sitemap == nil {
+				break
+			}
+			select {
+			case pages := <-sitemap:
+				c.queueSitemap(pages)
+			case <-c.ctx.Done():
+			}
+			sitemap = nil
+			continue
+		}
+		for _, l := range links {
+			if l.from != nil {
+				l.from.Visit(l.URL)
+			} else {
+				c.Visit(l.URL)
+			}
+		}
+		c.Wait()
+	}
+	if ctx.Err() != nil {
+		c.Results.stop(LimitCanceled)
+		return c.Results, ctx.Err()
+	} else if c.ctx.Err() != nil {
+		c.Results.stop(LimitDuration)
+	}
+	if c.Results.Limit != "" {
+		logrus.WithField("url", uri).WithField("limit", c.Results.Limit).WithField("queued", c.Frontier.Len()).Debug("crawl budget spent")
+	}
 	return c.Results, nil
 }
 
+// queueSitemap pushes the sitemap pages that look worth it, a sitemap lists everything. They sit as deep as the
+// links on the first page, so they don't take the crawl any deeper than following those would.
+func (c *SiteCrawler) queueSitemap(pages []string) {
+	for _, page := range pages {
+		if p, err := url.Parse(page); err == nil && ScoreLink(p, "") > 0 {
+			c.Frontier.Push(page, "", c.seed)
+		}
+	}
+}
+
+// CrawlCandidate is Crawl, remembering which candidate the results came from.
+func CrawlCandidate(ctx context.Context, cand *sources.Candidate, concurrent int, depth int, budget Budget) (*CrawlResult, error) {
+	results, err := Crawl(ctx, cand.URL, concurrent, depth, budget, cand.Seeds...)
+	results.Candidate = cand
+	return results, err
+}
+
 func (c *SiteCrawler) AllowSubdomains(u *url.URL, concurrent int) {
 	if domain, err := publicsuffix.EffectiveTLDPlusOne(u.String()); err == nil {
 //		c.URLFilters = append(c.URLFilters, regexp.MustCompile(`(?i)^http(s)://[a-zA-Z0-9\-_\.]*?`+regexp.QuoteMeta(domain)))
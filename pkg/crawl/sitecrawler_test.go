@@ -0,0 +1,174 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// site serves a homepage linking to pages that take as long as their path says to answer.
+func site(t *testing.T, links ...string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			if d, err := time.ParseDuration(strings.TrimPrefix(r.URL.Path, "/")); err == nil {
+				time.Sleep(d)
+			}
+			fmt.Fprintf(w, "<html><title>%s</title><body>page %s</body></html>", r.URL.Path, r.URL.Path)
+			return
+		}
+		var b strings.Builder
+		for _, l := range links {
+			fmt.Fprintf(&b, `<a href="/%s">%s</a>`, l, l)
+		}
+		fmt.Fprintf(w, "<html><title>home</title><body>%s</body></html>", b.String())
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// pages found on the same page share a colly Ctx, each still has to be timed from its own request.
+func TestCrawlElapsed(t *testing.T) {
+	s := site(t, "300ms", "1ms")
+	sitemaps := UseSitemaps
+	UseSitemaps = false
+	defer func() { UseSitemaps = sitemaps }()
+
+	res, err := Crawl(context.Background(), s.URL, 4, 2, Budget{MaxPages: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(res.Pages))
+	}
+	for _, p := range res.Pages {
+		switch {
+		case strings.HasSuffix(p.URL, "/300ms") && p.Elapsed < 300*time.Millisecond:
+			t.Errorf("%s took %s, want at least 300ms", p.URL, p.Elapsed)
+		case strings.HasSuffix(p.URL, "/1ms") && p.Elapsed > 200*time.Millisecond:
+			t.Errorf("%s took %s, want well under 300ms", p.URL, p.Elapsed)
+		case p.Elapsed <= 0:
+			t.Errorf("%s has no elapsed time", p.URL)
+		}
+	}
+	left := 0
+	res.started.Range(func(k, v interface{}) bool { left++; return true })
+	if left != 0 {
+		t.Errorf("%d start times left behind", left)
+	}
+}
+
+// colly's goroutines all report into the one CrawlResult.
+func TestCrawlResultConcurrent(t *testing.T) {
+	var (
+		cr      = NewCrawlResults()
+		budget  = Budget{MaxPages: 50}
+		wg      sync.WaitGroup
+		started int64
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if cr.start(budget) {
+				atomic.AddInt64(&started, 1)
+			}
+			u, _ := url.Parse(fmt.Sprintf("http://example.com/%d", i))
+			if i%4 == 0 {
+				cr.AddError()
+			} else {
+				cr.AddPage(u, &Page{URL: u.String()})
+			}
+			cr.AddBytes(10)
+			cr.Counts()
+		}(i)
+	}
+	wg.Wait()
+	if pages, errors := cr.Counts(); pages != 75 || errors != 25 {
+		t.Errorf("got %d pages and %d errors, want 75 and 25", pages, errors)
+	}
+	if started != 50 || cr.Requests != 50 || cr.Limit != LimitPages {
+		t.Errorf("started %d of %d requests, limit %q, want 50 and pages", started, cr.Requests, cr.Limit)
+	}
+	if cr.Bytes != 1000 || len(cr.Pages) != len(cr.Scraped) {
+		t.Errorf("got %d bytes, %d pages for %d urls", cr.Bytes, len(cr.Pages), len(cr.Scraped))
+	}
+}
+
+func TestCrawlAsync(t *testing.T) {
+	var links []string
+	for i := 0; i < 30; i++ {
+		links = append(links, fmt.Sprintf("about-%d", i))
+	}
+	for i := 0; i < 10; i++ {
+		// scored above the about pages, so they're fetched first.
+		links = append(links, fmt.Sprintf("legal-contact-broken-%d", i))
+	}
+	s := site(t, links...)
+	s.Config.Handler = broken(s.Config.Handler)
+	sitemaps := UseSitemaps
+	UseSitemaps = false
+	defer func() { UseSitemaps = sitemaps }()
+
+	res, err := Crawl(context.Background(), s.URL, 8, 2, Budget{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, errors := res.Counts()
+	if pages != 31 || errors != 10 || res.Requests != 41 || res.Limit != "" {
+		t.Errorf("got %d pages, %d errors from %d requests, limit %q, want 31, 10, 41 and none", pages, errors, res.Requests, res.Limit)
+	}
+
+	res, err = Crawl(context.Background(), s.URL, 8, 2, Budget{MaxPages: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages, _ := res.Counts(); res.Requests != 12 || pages > 12 || res.Limit != LimitPages {
+		t.Errorf("got %d pages from %d requests, limit %q, want 12 and pages", pages, res.Requests, res.Limit)
+	}
+
+	res, err = Crawl(context.Background(), s.URL, 8, 2, Budget{MaxErrors: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, errors := res.Counts(); errors < 3 || res.Limit != LimitErrors {
+		t.Errorf("got %d errors, limit %q, want at least 3 and errors", errors, res.Limit)
+	}
+}
+
+// once Crawl has returned nothing may write to its CrawlResult, not even a sitemap that was still being read when the
+// budget ran out. Run with -race.
+func TestCrawlResultAfterReturn(t *testing.T) {
+	s := sitemapSite(t, 500*time.Millisecond, 0)
+	for _, budget := range []Budget{{MaxDuration: 200 * time.Millisecond}, {MaxPages: 1}} {
+		res, err := Crawl(context.Background(), s.URL, 4, 2, budget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Limit == "" {
+			t.Errorf("%+v: crawl wasn't stopped by its budget", budget)
+		}
+		_ = fmt.Sprint(res.Candidate, res.Scraped, res.Pages, res.Errors, res.Requests, res.Bytes, res.Limit, res.Emails(), res.Text())
+		// long enough for anything left running to have had its go.
+		time.Sleep(time.Second)
+		_ = fmt.Sprint(res.Scraped, res.Pages, res.Errors, res.Requests, res.Bytes, res.Limit)
+	}
+}
+
+// broken answers 500 for the broken pages.
+func broken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "broken") {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,147 @@
+package crawl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// MaxSitemaps is how many sitemap files (indexes included) we read for one site.
+	MaxSitemaps = 10
+	// MaxSitemapBytes caps any one sitemap, uncompressed.
+	MaxSitemapBytes int64 = 10 << 20
+	// MaxSitemapURLs is how many page urls we take from them.
+	MaxSitemapURLs = 5000
+)
+
+type sitemapXML struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// Sitemaps lists the page urls in the site's sitemaps: those robots.txt names, or /sitemap.xml if it names none.
+// Sitemap indexes are followed and gzipped sitemaps unpacked, up to MaxSitemaps files and limit urls.
+func Sitemaps(ctx context.Context, client *http.Client, root *url.URL, limit int) []string {
+	return sitemaps(ctx, client, root, limit, nil, Budget{})
+}
+
+// sitemaps is Sitemaps with every file read counted against a crawl's budget, and none read once it's spent. res may
+// be nil.
+func sitemaps(ctx context.Context, client *http.Client, root *url.URL, limit int, res *CrawlResult, b Budget) []string {
+	read := func(uri string, max int64) ([]byte, error) {
+		if res == nil {
+			return get(ctx, client, uri, max)
+		}
+		if max = res.allowance(b, max); max <= 0 {
+			return nil, fmt.Errorf("no budget left for %s", uri)
+		}
+		body, err := get(ctx, client, uri, max)
+		res.AddBytes(len(body))
+		return body, err
+	}
+	base := &url.URL{Scheme: root.Scheme, Host: root.Host}
+	queue := robotsSitemaps(read, base.String()+"/robots.txt")
+	if len(queue) == 0 {
+		queue = []string{base.String() + "/sitemap.xml"}
+	}
+	var (
+		pages   []string
+		fetched = map[string]bool{}
+	)
+	for len(queue) > 0 && len(fetched) < MaxSitemaps && len(pages) < limit {
+		loc := queue[0]
+		queue = queue[1:]
+		if fetched[loc] {
+			continue
+		}
+		fetched[loc] = true
+		sm, err := fetchSitemap(read, loc)
+		if err != nil {
+			logrus.WithError(err).WithField("url", loc).Debug("bad sitemap")
+			continue
+		}
+		for _, s := range sm.Sitemaps {
+			queue = append(queue, strings.TrimSpace(s.Loc))
+		}
+		for _, u := range sm.URLs {
+			if len(pages) >= limit {
+				break
+			}
+			pages = append(pages, strings.TrimSpace(u.Loc))
+		}
+	}
+	return pages
+}
+
+// robotsSitemaps reads the Sitemap: lines of a robots.txt.
+func robotsSitemaps(read func(string, int64) ([]byte, error), uri string) []string {
+	body, err := read(uri, 512<<10)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, ":"); i > 0 && strings.EqualFold(strings.TrimSpace(line[:i]), "sitemap") {
+			if loc := strings.TrimSpace(line[i+1:]); loc != "" {
+				out = append(out, loc)
+			}
+		}
+	}
+	return out
+}
+
+func fetchSitemap(read func(string, int64) ([]byte, error), uri string) (*sitemapXML, error) {
+	body, err := read(uri, MaxSitemapBytes)
+	if err != nil {
+		return nil, err
+	}
+	// sitemap.xml.gz, unless the server already undid it for us.
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = ioutil.ReadAll(io.LimitReader(zr, MaxSitemapBytes)); err != nil {
+			return nil, err
+		}
+	}
+	var sm sitemapXML
+	if err := xml.Unmarshal(body, &sm); err != nil {
+		return nil, err
+	}
+	return &sm, nil
+}
+
+func get(ctx context.Context, client *http.Client, uri string, max int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", uri, r.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(r.Body, max))
+}
@@ -0,0 +1,210 @@
+package crawl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sitemapSite has a homepage linking to /contact, and a sitemap listing /about-us, which links on to /about-us/team.
+func sitemapSite(t *testing.T, delay time.Duration, padding int) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nSitemap: http://%s/sitemap.xml\n", r.Host)
+		case "/sitemap.xml":
+			time.Sleep(delay)
+			fmt.Fprintf(w, `<urlset><url><loc>http://%s/about-us</loc></url><url><loc>http://%s/blog</loc></url></urlset><!-- %s -->`,
+				r.Host, r.Host, strings.Repeat("x", padding))
+		case "/":
+			fmt.Fprint(w, `<html><body><a href="/contact">contact</a></body></html>`)
+		case "/about-us":
+			fmt.Fprint(w, `<html><body><a href="/about-us/team">team</a></body></html>`)
+		default:
+			fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Path)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func crawled(res *CrawlResult) map[string]int {
+	depths := map[string]int{}
+	for _, p := range res.Pages {
+		depths[p.URL[strings.Index(p.URL[len("http://"):], "/")+len("http://"):]] = p.Depth
+	}
+	return depths
+}
+
+// sitemap pages are as deep as the homepage's links, so they don't take the crawl any further than those do.
+func TestCrawlSitemapDepth(t *testing.T) {
+	s := sitemapSite(t, 0, 0)
+	res, err := Crawl(context.Background(), s.URL, 4, 2, Budget{MaxPages: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := crawled(res)
+	if got["/about-us"] != 2 || got["/contact"] != 2 {
+		t.Errorf("got %v, want /about-us and /contact at depth 2", got)
+	}
+	if _, ok := got["/about-us/team"]; ok {
+		t.Errorf("went past depth 2 through the sitemap: %v", got)
+	}
+	if _, ok := got["/blog"]; ok {
+		t.Errorf("crawled a sitemap page not worth it: %v", got)
+	}
+}
+
+// a slow sitemap mustn't eat the time we'd spend on the links we already have.
+func TestCrawlSlowSitemap(t *testing.T) {
+	s := sitemapSite(t, time.Second, 0)
+	res, err := Crawl(context.Background(), s.URL, 4, 2, Budget{MaxDuration: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := crawled(res)["/contact"]; !ok || res.Limit != LimitDuration {
+		t.Errorf("got %v, limit %q, want /contact and duration", crawled(res), res.Limit)
+	}
+	if g := sitemapReaders(); g != "" {
+		t.Errorf("sitemap reader still running after the crawl returned:\n%s", g)
+	}
+}
+
+// sitemapReaders is the stack of any goroutine still reading a sitemap for a crawl.
+func sitemapReaders() string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	var out []string
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(g, "crawl.Crawl.func") || strings.Contains(g, "crawl.sitemaps(") {
+			out = append(out, g)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// robots.txt and sitemaps are read out of the same byte budget as pages.
+func TestCrawlSitemapBytes(t *testing.T) {
+	s := sitemapSite(t, 0, 64<<10)
+	res, err := Crawl(context.Background(), s.URL, 4, 2, Budget{MaxBytes: 16 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pages answering while the sitemap's being read can take it a little over.
+	if res.Bytes > 17<<10 || res.Bytes < 8<<10 {
+		t.Errorf("read %d bytes, want the sitemap counted but cut off around 16KB", res.Bytes)
+	}
+	if _, ok := crawled(res)["/about-us"]; ok {
+		t.Errorf("read a sitemap the budget couldn't cover: %v", crawled(res))
+	}
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	return b.Bytes()
+}
+
+func urlset(host string, paths ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, p := range paths {
+		fmt.Fprintf(&b, "<url><loc>\n  http://%s%s\n</loc></url>", host, p)
+	}
+	b.WriteString("</urlset>")
+	return b.String()
+}
+
+// robots.txt names a sitemap index, whose sitemaps are plain, gzipped, missing, and one more index.
+func TestSitemaps(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		fetched []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		fetched = append(fetched, r.URL.Path)
+		lock.Unlock()
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /admin\n# Sitemap: http://%s/commented.xml\nSITEMAP:http://%[1]s/index.xml\n", r.Host)
+		case "/index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>http://%s/pages.xml</loc></sitemap><sitemap><loc>http://%[1]s/posts.xml.gz</loc></sitemap>
+				<sitemap><loc>http://%[1]s/gone.xml</loc></sitemap><sitemap><loc>http://%[1]s/more.xml</loc></sitemap></sitemapindex>`, r.Host)
+		case "/pages.xml":
+			fmt.Fprint(w, urlset(r.Host, "/", "/about-us", "/contact"))
+		case "/posts.xml.gz":
+			w.Header().Set("Content-Type", "application/x-gzip")
+			w.Write(gzipped(t, urlset(r.Host, "/blog/1", "/blog/2")))
+		case "/more.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>http://%s/index.xml</loc></sitemap><sitemap><loc>http://%[1]s/team.xml</loc></sitemap></sitemapindex>`, r.Host)
+		case "/team.xml":
+			fmt.Fprint(w, urlset(r.Host, "/team"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	root, _ := url.Parse(s.URL + "/somewhere/deep")
+
+	var want []string
+	for _, p := range []string{"/", "/about-us", "/contact", "/blog/1", "/blog/2", "/team"} {
+		want = append(want, s.URL+p)
+	}
+	if got := Sitemaps(context.Background(), s.Client(), root, MaxSitemapURLs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// the index that lists itself again is only read once, /sitemap.xml isn't needed.
+	wantFetched := []string{"/robots.txt", "/index.xml", "/pages.xml", "/posts.xml.gz", "/gone.xml", "/more.xml", "/team.xml"}
+	if !reflect.DeepEqual(fetched, wantFetched) {
+		t.Errorf("fetched %v, want %v", fetched, wantFetched)
+	}
+
+	// the url limit stops us reading more sitemaps than it takes to fill it.
+	fetched = nil
+	if got := Sitemaps(context.Background(), s.Client(), root, 4); !reflect.DeepEqual(got, want[:4]) {
+		t.Errorf("limit 4 got %v, want %v", got, want[:4])
+	}
+	if len(fetched) != 4 {
+		t.Errorf("limit 4 fetched %v, want robots.txt, the index and two sitemaps", fetched)
+	}
+
+	// and MaxSitemaps caps the files, indexes included.
+	max := MaxSitemaps
+	MaxSitemaps = 2
+	defer func() { MaxSitemaps = max }()
+	if got := Sitemaps(context.Background(), s.Client(), root, MaxSitemapURLs); !reflect.DeepEqual(got, want[:3]) {
+		t.Errorf("MaxSitemaps 2 got %v, want %v", got, want[:3])
+	}
+}
+
+// without a Sitemap: line in robots.txt, or any robots.txt, it's /sitemap.xml.
+func TestSitemapsDefault(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Write(gzipped(t, urlset(r.Host, "/about-us")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	root, _ := url.Parse(s.URL)
+	if got := Sitemaps(context.Background(), s.Client(), root, MaxSitemapURLs); len(got) != 1 || got[0] != s.URL+"/about-us" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,261 @@
+package sources
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/levigross/grequests"
+	"github.com/sirupsen/logrus"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Category is why a domain can't be the company's own site.
+type Category string
+
+const (
+	Registry  Category = "registry"  // official and unofficial company registers
+	Directory Category = "directory" // business listings, credit checkers, reviews
+	Social    Category = "social"
+	Reference Category = "reference" // encyclopedias, news, job boards
+	Platform  Category = "platform"  // search engines, marketplaces, hosting
+)
+
+// Blocklist maps registrable domains to why they're blocked.
+type Blocklist map[string]Category
+
+// DefaultBlocklist is what FindPossibleDomains filters with, add to it with Load.
+var DefaultBlocklist = Blocklist{
+	"companieshouse.gov.uk": Registry, "company-information.service.gov.uk": Registry, "opencorporates.com": Registry,
+	"endole.co.uk": Registry, "companycheck.co.uk": Registry, "companiesintheuk.co.uk": Registry,
+	"company-director-check.co.uk": Registry, "bizdb.co.uk": Registry, "northdata.com": Registry, "northdata.de": Registry,
+	"duedil.com": Registry, "companieslist.co.uk": Registry, "gleif.org": Registry, "lei-lookup.com": Registry,
+	"find-and-update.company-information.service.gov.uk": Registry, "suite.endole.co.uk": Registry,
+	"globaldatabase.com": Directory, "dnb.com": Directory, "zoominfo.com": Directory, "crunchbase.com": Directory,
+	"creditsafe.com": Directory, "yell.com": Directory, "yelp.com": Directory, "yelp.co.uk": Directory,
+	"thomsonlocal.com": Directory, "scoot.co.uk": Directory, "cylex-uk.co.uk": Directory, "192.com": Directory,
+	"checkatrade.com": Directory, "trustpilot.com": Directory, "freeindex.co.uk": Directory, "hotfrog.co.uk": Directory,
+	"ukphonebook.com": Directory, "bizstats.co.uk": Directory, "kompass.com": Directory, "manta.com": Directory,
+	"bloomberg.com": Directory, "rocketreach.co": Directory, "cbinsights.com": Directory, "owler.com": Directory,
+	"linkedin.com": Social, "facebook.com": Social, "twitter.com": Social, "x.com": Social, "instagram.com": Social,
+	"youtube.com": Social, "pinterest.com": Social, "pinterest.co.uk": Social, "tiktok.com": Social, "reddit.com": Social,
+	"wikipedia.org": Reference, "wikidata.org": Reference, "glassdoor.com": Reference, "glassdoor.co.uk": Reference,
+	"indeed.com": Reference, "indeed.co.uk": Reference, "bbc.co.uk": Reference, "theguardian.com": Reference,
+	"google.com": Platform, "google.co.uk": Platform, "bing.com": Platform, "duckduckgo.com": Platform,
+	"amazon.com": Platform, "amazon.co.uk": Platform, "ebay.com": Platform, "ebay.co.uk": Platform,
+	"apple.com": Platform, "wordpress.com": Platform, "blogspot.com": Platform, "wixsite.com": Platform,
+}
+
+// Classify says whether a url or domain is blocked, and why.
+func (b Blocklist) Classify(uri string) (Category, bool) {
+	domain := RegistrableDomain(uri)
+	if cat, ok := b[domain]; ok {
+		return cat, true
+	}
+	// government registers sit under gov.uk, which is a public suffix itself.
+	host := candidateHost(uri)
+	if host == "" {
+		host = strings.ToLower(uri)
+	}
+	for d, cat := range b {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return cat, true
+		}
+	}
+	return "", false
+}
+
+// Load adds "domain category" lines from a file, # starts a comment.
+func (b Blocklist) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		cat := Directory
+		if len(fields) > 1 {
+			cat = Category(fields[1])
+		} else if len(fields) != 1 {
+			return fmt.Errorf("%s:%d: expected 'domain [category]'", path, line)
+		}
+		b[strings.ToLower(fields[0])] = cat
+	}
+	return scanner.Err()
+}
+
+var (
+	// MaxMinedPages is how many blocked pages we'll fetch per company looking for links to the real site.
+	MaxMinedPages = 6
+	// MaxOutboundLinks is how many links off any one mined page become candidates, those naming the company first.
+	MaxOutboundLinks = 3
+
+	assetExt = regexp.MustCompile(`(?i)\.(pdf|jpe?g|png|gif|svg|webp|ico|css|js|zip|gz|docx?|xlsx?|pptx?|mp[34]|xml|json|txt)$`)
+)
+
+// OutboundLink is a link from a mined page to another site, and what it said.
+type OutboundLink struct {
+	URL  string
+	Text string
+}
+
+// FilterBlocked removes blocked candidates. Their deep links (a company's page on a directory, say) are fetched
+// and the sites they link out to come back as candidates instead, at most MaxOutboundLinks a page. Links naming the
+// company, cleaned name in name, in their text or domain go first, the rest is usually the directory's partners.
+func (b Blocklist) FilterBlocked(ctx context.Context, name string, cands []*Candidate) []*Candidate {
+	var (
+		kept  []*Candidate
+		pages []string
+		from  = map[string]*Candidate{}
+	)
+	for _, c := range cands {
+		cat, blocked := b.Classify(c.URL)
+		if !blocked {
+			kept = append(kept, c)
+			continue
+		}
+		logrus.WithField("url", c.URL).WithField("category", cat).Debug("blocked candidate")
+		for _, seed := range c.Seeds {
+			if len(pages) < MaxMinedPages {
+				if _, seen := from[seed]; !seen {
+					pages = append(pages, seed)
+					from[seed] = c
+				}
+			}
+		}
+	}
+
+	var (
+		wg    = sync.WaitGroup{}
+		mined = make([][]OutboundLink, len(pages))
+	)
+	for i, page := range pages {
+		wg.Add(1)
+		go func(i int, page string) {
+			defer wg.Done()
+			mctx, cancel := context.WithTimeout(ctx, SourceTimeout)
+			defer cancel()
+			links, err := MineOutbound(mctx, page)
+			if err != nil {
+				logrus.WithError(err).WithField("url", page).Debug("error mining page")
+			}
+			mined[i] = links
+		}(i, page)
+	}
+	wg.Wait()
+
+	words := nameWords(name)
+	for i, links := range mined {
+		var named, rest []OutboundLink
+		for _, link := range links {
+			if _, blocked := b.Classify(link.URL); blocked {
+				continue
+			}
+			if namesCompany(link, words) {
+				named = append(named, link)
+			} else {
+				rest = append(rest, link)
+			}
+		}
+		links = append(named, rest...)
+		if len(links) > MaxOutboundLinks {
+			links = links[:MaxOutboundLinks]
+		}
+		for rank, link := range links {
+			domain := RegistrableDomain(link.URL)
+			scheme := "http"
+			if strings.HasPrefix(link.URL, "https:") {
+				scheme = "https"
+			}
+			c := NewCandidate(scheme+"://"+domain, Hit{Source: "Outbound", Rank: rank, Query: pages[i]})
+			for _, h := range from[pages[i]].Hits {
+				// keep who found the page, so we can tell a linkedin link from a yell one.
+				c.Evidence = append(c.Evidence, Evidence{Source: h.Source, URL: pages[i]})
+			}
+			kept = append(kept, c)
+		}
+	}
+	return MergeCandidates(kept)
+}
+
+// nameWords are the words of a cleaned name worth looking for in a link.
+func nameWords(name string) []string {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(name)) {
+		if len(w) > 2 && !isGeneric(w) && !isConnective(w) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// namesCompany is whether the link's text or domain has any of words in it.
+func namesCompany(link OutboundLink, words []string) bool {
+	text := strings.ToLower(link.Text)
+	label := strings.Replace(strings.SplitN(RegistrableDomain(link.URL), ".", 2)[0], "-", "", -1)
+	for _, w := range words {
+		if strings.Contains(label, w) || strings.Contains(text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// MineOutbound fetches a page and returns its links to other sites, leaving out those in the page's header, footer
+// and navigation, which are the site's own boilerplate, and links to files rather than pages.
+func MineOutbound(ctx context.Context, page string) ([]OutboundLink, error) {
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	r, err := grequests.Get(page, ro)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, fmt.Errorf("got %d", r.StatusCode)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(page)
+	if err != nil {
+		return nil, err
+	}
+	if r.RawResponse != nil && r.RawResponse.Request != nil {
+		// relative links are relative to wherever we got redirected to.
+		base = r.RawResponse.Request.URL
+	}
+	own := RegistrableDomain(base.Hostname())
+	var (
+		links []OutboundLink
+		seen  = map[string]bool{}
+	)
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		if s.Closest("header, footer, nav, [role=navigation], [role=banner], [role=contentinfo]").Length() > 0 {
+			return
+		}
+		href, _ := s.Attr("href")
+		u, err := base.Parse(href)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return
+		}
+		if RegistrableDomain(u.Hostname()) == own || assetExt.MatchString(u.Path) || seen[u.String()] {
+			return
+		}
+		seen[u.String()] = true
+		links = append(links, OutboundLink{URL: u.String(), Text: strings.Join(strings.Fields(s.Text()), " ")})
+	})
+	return links, nil
+}
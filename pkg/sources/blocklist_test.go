@@ -0,0 +1,56 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// a directory's page for the company links to its site, among the directory's own boilerplate.
+func TestFilterBlockedMinesCompanyLink(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>
+			<header><a href="https://www.gov.uk/">GOV.UK</a><a href="https://partner-insurance.com/">Get insured</a></header>
+			<nav><a href="https://cdn-directory.net/home">Home</a></nav>
+			<main>
+				<a href="/company/other">Another company</a>
+				<a href="https://adserver.example.net/click?id=4">Advertise here</a>
+				<a href="https://static.directory-cdn.net/logo.png">logo</a>
+				<a href="https://www.facebook.com/sharer.php">Share</a>
+				<a href="https://credit-report.co.uk/">Check their credit</a>
+				<a href="https://loans-for-business.com/">Business loans</a>
+				<a href="https://www.acmewidgets.co.uk/">Visit website</a>
+			</main>
+			<footer><a href="https://ico.org.uk/">ICO</a><a href="https://twitter.com/directory">Follow us</a></footer>
+		</body></html>`)
+	}))
+	defer s.Close()
+
+	b := Blocklist{"127.0.0.1": Directory, "facebook.com": Social, "twitter.com": Social}
+	listing := NewCandidate(s.URL, Hit{Source: "Bing"})
+	listing.Seeds = []string{s.URL + "/company/01234567"}
+	kept := b.FilterBlocked(context.Background(), "acme widgets", []*Candidate{listing, NewCandidate("https://acme.com", Hit{Source: "TLD"})})
+
+	got := map[string]int{}
+	for _, c := range kept {
+		for _, h := range c.Hits {
+			if h.Source == "Outbound" {
+				got[c.Domain] = h.Rank
+			}
+		}
+	}
+	want := map[string]int{"acmewidgets.co.uk": 0, "example.net": 1, "credit-report.co.uk": 2}
+	if len(got) != len(want) {
+		t.Errorf("got outbound candidates %v, want %v", got, want)
+	}
+	for d, rank := range want {
+		if r, ok := got[d]; !ok || r != rank {
+			t.Errorf("%s at rank %d (found %v), want rank %d", d, r, ok, rank)
+		}
+	}
+	if len(kept) != len(want)+1 {
+		t.Errorf("got %d candidates, want the outbound ones and acme.com", len(kept))
+	}
+}
@@ -0,0 +1,127 @@
+package sources
+
+import (
+	"github.com/ip-rw/rank/pkg/util"
+	"golang.org/x/net/publicsuffix"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Hit is one source's reason for suggesting a candidate.
+type Hit struct {
+	Source string
+	Rank   int     // position in the source's results, 0 is best
+	Query  string  // what the source was asked
+	Score  float64 // whatever the source thinks of it, not comparable between sources
+}
+
+// Evidence is a search result that pointed at a candidate, kept so we can show why we believed it.
+type Evidence struct {
+	Source  string
+	URL     string
+	Title   string
+	Snippet string
+}
+
+// Candidate is a url we think might belong to the company, and who told us so.
+type Candidate struct {
+	URL      string
+	Domain   string   // registrable domain, e.g. example.co.uk
+	Seeds    []string // deep links worth crawling as well as URL
+	Aliases  []string // other domains that redirect here
+	Hits     []Hit
+	Evidence []Evidence
+
+	Registration *Registration
+	DNS          *DNS
+}
+
+func NewCandidate(uri string, hit Hit) *Candidate {
+	return &Candidate{URL: uri, Domain: RegistrableDomain(uri), Hits: []Hit{hit}}
+}
+
+// RegistrableDomain returns the eTLD+1 of a url or hostname, or the hostname itself if there isn't one.
+func RegistrableDomain(uri string) string {
+	host := uri
+	if strings.Contains(uri, "://") {
+		if u, err := url.Parse(uri); err == nil {
+			host = u.Hostname()
+		}
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+// Sources lists the sources that suggested this candidate.
+func (c *Candidate) Sources() []string {
+	var out []string
+	for _, h := range c.Hits {
+		out = util.AppendUniq(out, h.Source)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// BestRank is the best rank any source gave it.
+func (c *Candidate) BestRank() int {
+	best := -1
+	for _, h := range c.Hits {
+		if best < 0 || h.Rank < best {
+			best = h.Rank
+		}
+	}
+	return best
+}
+
+// Hit returns the hit from the named source, if there is one.
+func (c *Candidate) Hit(source string) (Hit, bool) {
+	for _, h := range c.Hits {
+		if h.Source == source {
+			return h, true
+		}
+	}
+	return Hit{}, false
+}
+
+// MergeCandidates folds candidates for the same registrable domain together, keeping the order they were first seen
+// in. http/https and www/non-www variants all become one candidate, ProbeCandidates works out which one to crawl.
+func MergeCandidates(in []*Candidate) []*Candidate {
+	var (
+		out      []*Candidate
+		byDomain = map[string]*Candidate{}
+	)
+	for _, c := range in {
+		key := c.Domain
+		if key == "" {
+			key = c.URL
+		}
+		if existing, ok := byDomain[key]; ok {
+			existing.merge(c)
+			continue
+		}
+		byDomain[key] = c
+		out = append(out, c)
+	}
+	return out
+}
+
+func (c *Candidate) merge(other *Candidate) {
+	c.Hits = append(c.Hits, other.Hits...)
+	c.Evidence = append(c.Evidence, other.Evidence...)
+	for _, seed := range other.Seeds {
+		c.Seeds = util.AppendUniq(c.Seeds, seed)
+	}
+	for _, alias := range other.Aliases {
+		c.Aliases = util.AppendUniq(c.Aliases, alias)
+	}
+	if c.Registration == nil {
+		c.Registration = other.Registration
+	}
+	if c.DNS == nil {
+		c.DNS = other.DNS
+	}
+}
@@ -0,0 +1,113 @@
+package sources
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ProbeTimeout bounds each variant we try.
+	ProbeTimeout = 10 * time.Second
+	// ProbeConcurrency is how many candidates are probed at once.
+	ProbeConcurrency = 16
+)
+
+// Variants are the scheme and host combinations tried for a domain, best first.
+func Variants(domain string) []string {
+	return []string{
+		"https://" + domain,
+		"https://www." + domain,
+		"http://" + domain,
+		"http://www." + domain,
+	}
+}
+
+// Probe tries each variant of a domain and returns scheme://host of wherever the first one that answers ends up.
+func Probe(ctx context.Context, client *http.Client, domain string) (string, bool) {
+	for _, v := range Variants(domain) {
+		if final, ok := probe(ctx, client, v); ok {
+			return final, true
+		}
+	}
+	return "", false
+}
+
+func probe(ctx context.Context, client *http.Client, uri string) (string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return "", false
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", false
+	}
+	// drain a little so the connection can be reused, we only care where we landed.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return "", false
+	}
+	u := resp.Request.URL
+	return u.Scheme + "://" + u.Host, true
+}
+
+// ProbeCandidates settles every candidate on the variant that actually serves a site, following redirects.
+// candidates that redirect onto another registrable domain are folded into it and remembered as aliases,
+// candidates where nothing answers, or that end up somewhere on DefaultBlocklist, are dropped. a nil client uses the
+// shared transport.
+func ProbeCandidates(ctx context.Context, client *http.Client, cands []*Candidate) []*Candidate {
+	if client == nil {
+		client = util.HTTPClient()
+	}
+	var (
+		finals = make([]string, len(cands))
+		sem    = make(chan struct{}, ProbeConcurrency)
+		wg     sync.WaitGroup
+	)
+	for i, c := range cands {
+		wg.Add(1)
+		go func(i int, c *Candidate) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			domain := c.Domain
+			if domain == "" {
+				domain = candidateHost(c.URL)
+			}
+			if final, ok := Probe(ctx, client, domain); ok {
+				finals[i] = final
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	var out []*Candidate
+	for i, c := range cands {
+		if finals[i] == "" {
+			logrus.WithField("domain", c.Domain).Debug("nothing answered, dropping")
+			continue
+		}
+		c.URL = finals[i]
+		if d := RegistrableDomain(c.URL); d != c.Domain {
+			logrus.WithField("from", c.Domain).WithField("to", d).Debug("redirects elsewhere")
+			c.Aliases = util.AppendUniq(c.Aliases, c.Domain)
+			c.Domain = d
+		}
+		// plenty of parked and abandoned domains redirect to a facebook or linkedin page.
+		if cat, blocked := DefaultBlocklist.Classify(c.URL); blocked {
+			logrus.WithField("url", c.URL).WithField("category", cat).Debug("redirects somewhere blocked, dropping")
+			continue
+		}
+		out = append(out, c)
+	}
+	return MergeCandidates(out)
+}
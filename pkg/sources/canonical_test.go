@@ -0,0 +1,52 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// toServer sends every request to s, whatever host it was for.
+type toServer struct{ s *httptest.Server }
+
+func (t toServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(t.s.URL)
+	r := req.Clone(req.Context())
+	r.URL.Scheme, r.URL.Host, r.Host = u.Scheme, u.Host, req.URL.Host
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err == nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+func TestProbeCandidates(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host {
+		case "acme.com", "www.facebook.com":
+			w.Write([]byte("<html></html>"))
+		case "acme.co.uk":
+			http.Redirect(w, r, "https://acme.com/", http.StatusMovedPermanently)
+		case "acme-old.com":
+			http.Redirect(w, r, "https://www.facebook.com/acme", http.StatusFound)
+		default:
+			http.Error(w, "down", http.StatusBadGateway)
+		}
+	}))
+	defer s.Close()
+	client := &http.Client{Transport: toServer{s}}
+
+	var cands []*Candidate
+	for _, u := range []string{"http://acme.com", "http://acme.co.uk", "http://acme-old.com", "http://acme-down.com"} {
+		cands = append(cands, NewCandidate(u, Hit{Source: "Test"}))
+	}
+	out := ProbeCandidates(context.Background(), client, cands)
+	if len(out) != 1 {
+		t.Fatalf("got %d candidates, want just acme.com", len(out))
+	}
+	if c := out[0]; c.URL != "https://acme.com" || c.Domain != "acme.com" || len(c.Aliases) != 1 || c.Aliases[0] != "acme.co.uk" {
+		t.Errorf("got %s (%s) aliases %v, want https://acme.com with acme.co.uk folded in", c.URL, c.Domain, c.Aliases)
+	}
+}
@@ -0,0 +1,109 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/levigross/grequests"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const CrtShURL = "https://crt.sh/"
+
+func init() {
+	Register("CertificateTransparency", "{{.Name}}", true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return CertificateTransparency{Jurisdiction: j, BaseURL: opts["url"]}
+	})
+}
+
+// CertificateTransparency searches a crt.sh compatible CT log search for certificates issued to the company
+// (subject O=, its registered name), finding domains that search engines never show us.
+type CertificateTransparency struct {
+	Jurisdiction *Jurisdiction
+	BaseURL      string
+}
+
+type ctEntry struct {
+	ID         int64  `json:"id"`
+	IssuerName string `json:"issuer_name"`
+	CommonName string `json:"common_name"`
+	NameValue  string `json:"name_value"`
+	NotBefore  string `json:"not_before"`
+	NotAfter   string `json:"not_after"`
+}
+
+func (c CertificateTransparency) Name() string {
+	return "CertificateTransparency"
+}
+
+func (c CertificateTransparency) Lookup(ctx context.Context, company string) ([]*Candidate, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = CrtShURL
+	}
+	// crt.sh matches O= exactly, and certificates carry the name as registered, suffix and all.
+	query := strings.Join(strings.Fields(company), " ")
+	if c.Jurisdiction.Clean(company) == "" {
+		return nil, nil
+	}
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Params = map[string]string{"O": query, "output": "json"}
+	r, err := grequests.Get(base, ro)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, fmt.Errorf("ct search returned %d", r.StatusCode)
+	}
+	var entries []ctEntry
+	if err = r.JSON(&entries); err != nil {
+		return nil, err
+	}
+
+	// every certificate names a handful of hosts, count certificates per registrable domain.
+	certs := map[string]int{}
+	for _, e := range entries {
+		seen := map[string]bool{}
+		for _, name := range append(strings.Split(e.NameValue, "\n"), e.CommonName) {
+			if domain := ctDomain(name); domain != "" && !seen[domain] {
+				seen[domain] = true
+				certs[domain]++
+			}
+		}
+	}
+	domains := make([]string, 0, len(certs))
+	for d := range certs {
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if certs[domains[i]] != certs[domains[j]] {
+			return certs[domains[i]] > certs[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	out := make([]*Candidate, 0, len(domains))
+	for i, d := range domains {
+		out = append(out, NewCandidate("https://"+d, Hit{Source: c.Name(), Rank: i, Query: query, Score: float64(certs[d])}))
+	}
+	return out, nil
+}
+
+// ctDomain turns a SAN/CN into a registrable domain, dropping wildcards, ip addresses and anything else that isn't
+// a hostname.
+func ctDomain(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimPrefix(name, "*.")
+	if name == "" || strings.ContainsAny(name, " @/:*") || !strings.Contains(name, ".") {
+		return ""
+	}
+	if u, err := url.Parse("http://" + name); err != nil || u.Hostname() != name {
+		return ""
+	}
+	if strings.Trim(name, "0123456789.") == "" {
+		return ""
+	}
+	return RegistrableDomain(name)
+}
@@ -0,0 +1,52 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCertificateTransparency(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the subject's O is the registered name, not our cleaned up one.
+		if q := r.URL.Query(); q.Get("O") != "Acme Widgets Ltd" || q.Get("output") != "json" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`[
+			{"id":1,"common_name":"acme.com","name_value":"acme.com\n*.acme.com\nwww.acme.com"},
+			{"id":2,"common_name":"shop.acme.com","name_value":"shop.acme.com\nacme-widgets.co.uk"},
+			{"id":3,"common_name":"192.0.2.1","name_value":"192.0.2.1\nlocalhost\nadmin@acme.com\nACME Widgets Ltd"}
+		]`))
+	}))
+	defer s.Close()
+
+	ct := CertificateTransparency{Jurisdiction: LookupJurisdiction("gb"), BaseURL: s.URL}
+	cands, err := ct.Lookup(context.Background(), " Acme  Widgets Ltd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		domain string
+		certs  float64
+	}{{"acme.com", 2}, {"acme-widgets.co.uk", 1}}
+	if len(cands) != len(want) {
+		t.Fatalf("got %d candidates, want %d", len(cands), len(want))
+	}
+	for i, w := range want {
+		if c := cands[i]; c.Domain != w.domain || c.Hits[0].Score != w.certs || c.Hits[0].Rank != i {
+			t.Errorf("candidate %d is %s with %v certificates at rank %d, want %s with %v", i, c.Domain, c.Hits[0].Score, c.Hits[0].Rank, w.domain, w.certs)
+		}
+	}
+}
+
+func TestCertificateTransparencyError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	ct := CertificateTransparency{BaseURL: s.URL}
+	if _, err := ct.Lookup(context.Background(), "Acme Widgets"); err == nil {
+		t.Error("expected an error from a 503")
+	}
+}
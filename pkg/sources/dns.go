@@ -0,0 +1,187 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Resolver is the bit of *net.Resolver we need, so tests and odd setups can bring their own.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupNS(ctx context.Context, name string) ([]*net.NS, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NewResolver talks to one DNS server (host:port) instead of whatever the system uses.
+func NewResolver(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// DNS is what we found out about a candidate's host and domain.
+type DNS struct {
+	A      []string
+	AAAA   []string
+	MX     []string
+	NS     []string
+	TXT    []string
+	Parked bool // nameservers belong to a domain parking service
+}
+
+// ParkingNameservers are the nameserver domains of parking and domain resale services.
+var ParkingNameservers = []string{
+	"sedoparking.com", "parkingcrew.net", "bodis.com", "above.com", "dan.com", "afternic.com",
+	"parklogic.com", "smartname.com", "uniregistrymarket.link", "ztomy.com", "huge-domains.com",
+	"hugedomains.com", "namebrightdns.com", "domainparkingserver.net", "parked.com", "fabulous.com",
+}
+
+// ParkedPenalty comes off the score of candidates on parking nameservers.
+var ParkedPenalty = 0.3
+
+func (d *DNS) Adjustment() float64 {
+	if d != nil && d.Parked {
+		return -ParkedPenalty
+	}
+	return 0
+}
+
+// ResolveCandidates looks every candidate up, dropping the ones whose host doesn't exist. Anything that fails for
+// another reason (timeouts, servfail) is kept, we'd rather crawl it than lose it.
+func ResolveCandidates(ctx context.Context, r Resolver, cands []*Candidate) []*Candidate {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	type answer struct {
+		exists bool
+		ips    []net.IPAddr
+	}
+	var (
+		wg      = sync.WaitGroup{}
+		sem     = make(chan struct{}, 16)
+		hosts   = map[string]*answer{}
+		domains = map[string]*DNS{}
+	)
+	for _, c := range cands {
+		for _, host := range candidateHosts(c) {
+			if _, ok := hosts[host]; ok {
+				continue
+			}
+			a := &answer{exists: true}
+			hosts[host] = a
+			wg.Add(1)
+			// the map is only ours, each lookup gets its own answer to fill in.
+			go func(host string, a *answer) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				ips, err := r.LookupIPAddr(ctx, host)
+				a.exists = !isNotFound(err)
+				a.ips = ips
+			}(host, a)
+		}
+		if _, ok := domains[c.Domain]; !ok && c.Domain != "" {
+			domains[c.Domain] = &DNS{}
+			wg.Add(1)
+			go func(domain string, d *DNS) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				lookupDomain(ctx, r, domain, d)
+			}(c.Domain, domains[c.Domain])
+		}
+	}
+	wg.Wait()
+
+	var out []*Candidate
+	for _, c := range cands {
+		// the bare domain, www. or the url's own host will do, probing picks between them later.
+		var (
+			exists = false
+			ips    []net.IPAddr
+		)
+		for _, host := range candidateHosts(c) {
+			if a := hosts[host]; a.exists {
+				exists = true
+				if len(ips) == 0 {
+					ips = a.ips
+				}
+			}
+		}
+		if !exists {
+			logrus.WithField("url", c.URL).Debug("nxdomain, dropping")
+			continue
+		}
+		d := &DNS{}
+		if base := domains[c.Domain]; base != nil {
+			*d = *base
+		}
+		for _, ip := range ips {
+			if ip.IP.To4() != nil {
+				d.A = append(d.A, ip.IP.String())
+			} else {
+				d.AAAA = append(d.AAAA, ip.IP.String())
+			}
+		}
+		c.DNS = d
+		out = append(out, c)
+	}
+	return out
+}
+
+func lookupDomain(ctx context.Context, r Resolver, domain string, d *DNS) {
+	if mx, err := r.LookupMX(ctx, domain); err == nil {
+		for _, m := range mx {
+			d.MX = append(d.MX, strings.TrimSuffix(strings.ToLower(m.Host), "."))
+		}
+	}
+	if ns, err := r.LookupNS(ctx, domain); err == nil {
+		for _, n := range ns {
+			host := strings.TrimSuffix(strings.ToLower(n.Host), ".")
+			d.NS = append(d.NS, host)
+			for _, p := range ParkingNameservers {
+				if host == p || strings.HasSuffix(host, "."+p) {
+					d.Parked = true
+				}
+			}
+		}
+		sort.Strings(d.NS)
+	}
+	if txt, err := r.LookupTXT(ctx, domain); err == nil {
+		d.TXT = txt
+	}
+}
+
+func candidateHosts(c *Candidate) []string {
+	var hosts []string
+	for _, h := range []string{candidateHost(c.URL), c.Domain, "www." + c.Domain} {
+		if h != "" && h != "www." {
+			hosts = util.AppendUniq(hosts, h)
+		}
+	}
+	return hosts
+}
+
+func candidateHost(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return ""
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
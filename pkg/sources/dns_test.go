@@ -0,0 +1,74 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeResolver resolves acme and parked hosts and says nxdomain for everything else.
+type fakeResolver struct{}
+
+func (f fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	switch {
+	case strings.HasPrefix(host, "acme"):
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}}, nil
+	case strings.HasPrefix(host, "www.parked"):
+		return []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (f fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return []*net.MX{{Host: "mx." + name + ".", Pref: 10}}, nil
+}
+
+func (f fakeResolver) LookupNS(ctx context.Context, name string) ([]*net.NS, error) {
+	if strings.HasPrefix(name, "parked") {
+		return []*net.NS{{Host: "ns1.sedoparking.com."}}, nil
+	}
+	return []*net.NS{{Host: "ns1." + name + "."}}, nil
+}
+
+func (f fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, nil
+}
+
+// lookups that answer while later hosts are still being queued used to race on the answers map.
+func TestResolveCandidates(t *testing.T) {
+	for run := 0; run < 10; run++ {
+		testResolveCandidates(t)
+	}
+}
+
+func testResolveCandidates(t *testing.T) {
+	var cands []*Candidate
+	for i := 0; i < 50; i++ {
+		cands = append(cands,
+			NewCandidate(fmt.Sprintf("https://acme%d.com/", i), Hit{Source: "Test"}),
+			NewCandidate(fmt.Sprintf("https://www.parked%d.com/", i), Hit{Source: "Test"}),
+			NewCandidate(fmt.Sprintf("https://missing%d.com/", i), Hit{Source: "Test"}),
+			NewCandidate(fmt.Sprintf("https://acme%d.com/", i), Hit{Source: "Test"}),
+		)
+	}
+	out := ResolveCandidates(context.Background(), fakeResolver{}, cands)
+	if len(out) != 150 {
+		t.Fatalf("got %d candidates, want 150", len(out))
+	}
+	for _, c := range out {
+		switch {
+		case strings.HasPrefix(c.Domain, "acme"):
+			if len(c.DNS.A) != 1 || c.DNS.Parked || len(c.DNS.MX) != 1 {
+				t.Errorf("acme.com dns = %+v", c.DNS)
+			}
+		case strings.HasPrefix(c.Domain, "parked"):
+			if len(c.DNS.AAAA) != 1 || !c.DNS.Parked {
+				t.Errorf("parked.com dns = %+v", c.DNS)
+			}
+		default:
+			t.Errorf("%s should have been dropped", c.Domain)
+		}
+	}
+}
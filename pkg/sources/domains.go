@@ -1,34 +1,122 @@
 package sources
 
 import (
+	"context"
+	"fmt"
 	"github.com/ip-rw/rank/pkg/util"
 	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 type DomainSource interface {
 	Name() string
-	Lookup(string) ([]string, error)
+	Lookup(context.Context, string) ([]*Candidate, error)
 }
 
-func FindPossibleDomains(c *util.Company) []string {
-	company := c.Name
-	//pc := c.RegisteredAddress.PostalCode
-	var urls []string
-	var modules = map[DomainSource]string {
-		TLD{}: company,
-		DuckDuckGo{}: company + " \"" + c.CompanyNumber + "\"",
-		Clearbit{}: company	,
-	}
-
-	for m, search := range modules {
-		if res, err := m.Lookup(search); err != nil {
-			logrus.WithError(err).WithField("source", m.Name()).Error("source error")
-		} else {
-			for _, domain := range res {
-				logrus.WithField("source", m.Name()).WithField("domain", domain).WithField("search", CleanCompanyName(search)).Debug("new domain")
-				urls = util.AppendUniq(urls, domain)
+// SourceTimeout is how long any one source gets before we carry on without it.
+var SourceTimeout = 20 * time.Second
+
+// SourceErrors holds the error from every source that failed, keyed by source name, or "name#n" (n its place in the
+// config) when the config runs a source more than once.
+type SourceErrors map[string]error
+
+func (e SourceErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e[name].Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// FindPossibleDomains runs every source in cfg (DefaultConfig if nil) at once and returns whatever they found, less
+// anything on DefaultBlocklist. If some sources failed the candidates from the rest are still returned, along with
+// a SourceErrors.
+func FindPossibleDomains(ctx context.Context, c *util.Company, cfg *Config) ([]*Candidate, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	j := JurisdictionFor(c)
+	var (
+		wg    = sync.WaitGroup{}
+		lock  = sync.Mutex{}
+		found = make([][]*Candidate, len(cfg.Sources))
+		errs  = SourceErrors{}
+	)
+	// everything that can fail up front gets done before any lookup starts, so only the lookups touch errs concurrently.
+	runs := make([]DomainSource, len(cfg.Sources))
+	searches := make([]string, len(cfg.Sources))
+	keys := sourceKeys(cfg.Sources)
+	for i := range cfg.Sources {
+		sc := &cfg.Sources[i]
+		factory, _, err := sc.build()
+		if err != nil {
+			errs[keys[i]] = err
+			continue
+		}
+		search, err := sc.Render(c)
+		if err != nil {
+			errs[keys[i]] = err
+			continue
+		}
+		runs[i], searches[i] = factory(c, j, sc.Options), search
+	}
+	for i, m := range runs {
+		if m == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, m DomainSource, search string) {
+			defer wg.Done()
+			sctx, cancel := context.WithTimeout(ctx, SourceTimeout)
+			defer cancel()
+			res, err := m.Lookup(sctx, search)
+			if err != nil {
+				logrus.WithError(err).WithField("source", keys[i]).Debug("source error")
+				lock.Lock()
+				errs[keys[i]] = err
+				lock.Unlock()
+				return
 			}
+			found[i] = res
+		}(i, m, searches[i])
+	}
+	wg.Wait()
+
+	// merge in config order so the same answers always give the same list.
+	var all []*Candidate
+	for _, res := range found {
+		for _, cand := range res {
+			logrus.WithField("domain", cand.URL).WithField("sources", cand.Sources()).WithField("rank", cand.BestRank()).Debug("new domain")
+			all = append(all, cand)
+		}
+	}
+	all = DefaultBlocklist.FilterBlocked(ctx, j.Clean(c.Name), MergeCandidates(all))
+	if len(errs) > 0 {
+		return all, errs
+	}
+	return all, nil
+}
+
+// sourceKeys names each config entry for SourceErrors.
+func sourceKeys(scs []SourceConfig) []string {
+	count := map[string]int{}
+	for _, sc := range scs {
+		count[sc.Name]++
+	}
+	keys := make([]string, len(scs))
+	for i, sc := range scs {
+		keys[i] = sc.Name
+		if count[sc.Name] > 1 {
+			keys[i] = fmt.Sprintf("%s#%d", sc.Name, i)
 		}
 	}
-	return urls
+	return keys
 }
@@ -0,0 +1,61 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ip-rw/rank/pkg/util"
+)
+
+type failingSource struct{ err error }
+
+func (f failingSource) Name() string { return "Failing" }
+
+func (f failingSource) Lookup(ctx context.Context, q string) ([]*Candidate, error) {
+	time.Sleep(10 * time.Millisecond)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []*Candidate{NewCandidate("https://"+q+".com", Hit{Source: "Failing"})}, nil
+}
+
+func init() {
+	Register("Failing", "{{.Name}}", false, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		if opts["fail"] != "" {
+			return failingSource{err: errors.New(opts["fail"])}
+		}
+		return failingSource{}
+	})
+}
+
+// a template that fails to render after earlier sources are already running used to race their error writes.
+func TestFindPossibleDomainsErrors(t *testing.T) {
+	cfg := &Config{Sources: []SourceConfig{
+		{Name: "Failing", Options: map[string]string{"fail": "first"}},
+		{Name: "Failing", Options: map[string]string{"fail": "second"}},
+		{Name: "Failing", Query: "{{index .PreviousNames 0}}"},
+		{Name: "Nonexistent"},
+		{Name: "Failing", Query: "acme"},
+	}}
+	cands, err := FindPossibleDomains(context.Background(), &util.Company{Name: "Acme Ltd", JurisdictionCode: "gb"}, cfg)
+	errs, ok := err.(SourceErrors)
+	if !ok {
+		t.Fatalf("expected SourceErrors, got %v", err)
+	}
+	for key, want := range map[string]string{"Failing#0": "first", "Failing#1": "second"} {
+		if errs[key] == nil || errs[key].Error() != want {
+			t.Errorf("errs[%s] = %v, want %s", key, errs[key], want)
+		}
+	}
+	if errs["Failing#2"] == nil || errs["Nonexistent"] == nil {
+		t.Errorf("missing render or build errors: %v", errs)
+	}
+	if len(errs) != 4 {
+		t.Errorf("got %d errors, want 4: %v", len(errs), errs)
+	}
+	if len(cands) != 1 || cands[0].Domain != "acme.com" {
+		t.Errorf("got candidates %v", cands)
+	}
+}
@@ -0,0 +1,76 @@
+package sources
+
+import (
+	"context"
+	"github.com/ip-rw/rank/pkg/util"
+)
+
+// GroupWebsiteScore is the prior of a parent's declared website, a child's gets half.
+var GroupWebsiteScore = 0.5
+
+func init() {
+	Register("GLEIF", "{{.Number}}", true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		g := util.NewGLEIF()
+		if opts["url"] != "" {
+			g.BaseURL = opts["url"]
+		}
+		return GLEIF{Company: c, Client: g}
+	})
+}
+
+// GLEIF returns whatever websites the company's LEI record declares. Level 1 data has nowhere to put one so this is
+// usually nothing, but when there is one it's the company's own say so. A company util.GLEIF.Enrich has already
+// found the LEI of isn't looked up again, its websites are what the record had, and its parents' and children's
+// come back too, less sure: subsidiaries often live on the group's site.
+type GLEIF struct {
+	Company *util.Company
+	Client  *util.GLEIF
+}
+
+func (g GLEIF) Name() string {
+	return "GLEIF"
+}
+
+func (g GLEIF) Lookup(ctx context.Context, number string) ([]*Candidate, error) {
+	if g.Company == nil {
+		return nil, nil
+	}
+	sites, query := g.Company.Websites, g.Company.LEI
+	if g.Company.LEI == "" {
+		var (
+			r   *util.LEIRecord
+			err error
+		)
+		if lei := g.Company.LEICode(); lei != "" {
+			r, err = g.Client.LookupLEI(ctx, lei)
+		} else {
+			r, err = g.Client.FindLEI(ctx, g.Company.JurisdictionCode, number)
+		}
+		if err == util.ErrCompanyNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		sites, query = r.Websites, r.LEI
+	}
+	var out []*Candidate
+	for i, site := range sites {
+		r := SearchResult{URL: site, Title: g.Company.Name, Snippet: "declared website"}
+		if c := ResultCandidate(r, Hit{Source: g.Name(), Rank: i, Query: query, Score: 1}); c != nil {
+			out = append(out, c)
+		}
+	}
+	for _, rel := range g.Company.LEIRelationships {
+		score := GroupWebsiteScore
+		if rel.Relationship == "child" {
+			score /= 2
+		}
+		for _, site := range rel.Websites {
+			r := SearchResult{URL: site, Title: rel.Name, Snippet: rel.Relationship + " declared website"}
+			if c := ResultCandidate(r, Hit{Source: g.Name(), Rank: len(out), Query: rel.LEI, Score: score}); c != nil {
+				out = append(out, c)
+			}
+		}
+	}
+	return MergeCandidates(out), nil
+}
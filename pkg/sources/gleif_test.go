@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ip-rw/rank/pkg/util"
+)
+
+const gleifRecordJSON = `{"data":[{"id":"213800ABCDEF12345678","attributes":{"lei":"213800ABCDEF12345678",
+	"entity":{"legalName":{"name":"ACME WIDGETS LIMITED"},"jurisdiction":"GB","registeredAs":"01234567",
+	"legalAddress":{"addressLines":["1 High Street"],"city":"London","country":"GB"},
+	"otherNames":[{"name":"http://acme-widgets.co.uk","type":"TRADING_OR_OPERATING_NAME"}]}}}]}`
+
+// once Enrich has the LEI the source only reads the company, it doesn't go back to gleif.
+func TestGLEIFEnriched(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("asked gleif for %s", r.URL)
+		http.NotFound(w, r)
+	}))
+	defer s.Close()
+	c := &util.Company{Name: "Acme Widgets Ltd", CompanyNumber: "01234567", JurisdictionCode: "gb", LEI: "213800ABCDEF12345678",
+		LEIRelationships: []util.LEIRelationship{
+			{LEI: "P", Name: "Acme Group", Relationship: "ultimate_parent", Websites: []string{"https://acmegroup.com/"}},
+			{LEI: "C", Name: "Acme Widgets Ireland", Relationship: "child", Websites: []string{"https://acme.ie/"}},
+		}}
+	g := GLEIF{Company: c, Client: &util.GLEIF{BaseURL: s.URL, Client: s.Client()}}
+	cands, err := g.Lookup(context.Background(), c.CompanyNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := map[string]float64{}
+	for _, cand := range cands {
+		scores[cand.Domain] = cand.Hits[0].Score
+	}
+	if len(scores) != 2 || scores["acmegroup.com"] != GroupWebsiteScore || scores["acme.ie"] != GroupWebsiteScore/2 {
+		t.Errorf("got %v, want the parent's and child's websites", scores)
+	}
+}
+
+// without Enrich the source finds the record itself.
+func TestGLEIFLookup(t *testing.T) {
+	asked := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked++
+		if r.URL.Path != "/lei-records" || r.URL.Query().Get("filter[entity.registeredAs]") != "01234567" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(gleifRecordJSON))
+	}))
+	defer s.Close()
+	c := &util.Company{Name: "Acme Widgets Ltd", CompanyNumber: "01234567", JurisdictionCode: "gb"}
+	g := GLEIF{Company: c, Client: &util.GLEIF{BaseURL: s.URL, Client: s.Client()}}
+	cands, err := g.Lookup(context.Background(), c.CompanyNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asked != 1 || len(cands) != 1 || cands[0].Domain != "acme-widgets.co.uk" || cands[0].Hits[0].Query != "213800ABCDEF12345678" {
+		t.Errorf("got %v after %d requests, want acme-widgets.co.uk from one", cands, asked)
+	}
+}
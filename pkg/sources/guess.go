@@ -0,0 +1,188 @@
+package sources
+
+import (
+	"context"
+	"github.com/ip-rw/rank/pkg/util"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("TLD", "{{.Name}}", true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		t := TLD{Jurisdiction: j, PreviousNames: c.PreviousNameList()}
+		if tlds := opts["tlds"]; tlds != "" {
+			for _, tld := range strings.Split(tlds, ",") {
+				if tld = strings.TrimSpace(tld); tld != "" {
+					t.TLDs = append(t.TLDs, "."+strings.TrimPrefix(tld, "."))
+				}
+			}
+		}
+		if limit, err := strconv.Atoi(opts["limit"]); err == nil {
+			t.Limit = limit
+		}
+		return t
+	})
+}
+
+// TLD guesses domains from the company's name. Every guess carries a prior (Hit.Score) of how likely that form of
+// the name is to be the one the company actually registered.
+type TLD struct {
+	Jurisdiction  *Jurisdiction
+	TLDs          []string // defaults to the jurisdiction's
+	PreviousNames []string
+	Limit         int // most urls to return, 0 is DefaultGuessLimit
+}
+
+var (
+	DefaultGuessLimit = 40
+	// GenericWords get dropped from names to make shorter guesses, "acme holdings uk" is often just acme.co.uk.
+	GenericWords = []string{"holdings", "holding", "group", "services", "service", "uk", "gb", "international",
+		"solutions", "enterprises", "trading", "consulting", "consultants", "management", "company", "and", "of"}
+	// Connectives join words rather than name anything, "smithand" is never anyone's domain.
+	Connectives = []string{"and", "of", "the", "for", "at", "in"}
+	// PreviousNamePrior scales the priors of guesses made from previous names.
+	PreviousNamePrior = 0.5
+
+	label = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+func (c TLD) Name() string {
+	return "TLD"
+}
+
+func (c TLD) Lookup(ctx context.Context, company string) ([]*Candidate, error) {
+	priors := map[string]float64{}
+	add := func(l string, prior float64) {
+		if len(l) > 1 && label.MatchString(l) && prior > priors[l] {
+			priors[l] = prior
+		}
+	}
+	for l, p := range c.labels(company) {
+		add(l, p)
+	}
+	for _, pn := range c.PreviousNames {
+		for l, p := range c.labels(pn) {
+			add(l, p*PreviousNamePrior)
+		}
+	}
+
+	tlds := c.TLDs
+	if len(tlds) == 0 && c.Jurisdiction != nil {
+		tlds = c.Jurisdiction.TLDs
+	}
+	if len(tlds) == 0 {
+		tlds = []string{".co.uk", ".com"}
+	}
+	var out []*Candidate
+	for l, p := range priors {
+		for i, tld := range tlds {
+			tp := p / (1 + 0.5*float64(i))
+			// www or not, and which scheme, is for probing to find out.
+			out = append(out, NewCandidate("http://"+l+tld, Hit{Source: c.Name(), Query: company, Score: tp}))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hits[0].Score != out[j].Hits[0].Score {
+			return out[i].Hits[0].Score > out[j].Hits[0].Score
+		}
+		return out[i].URL < out[j].URL
+	})
+	limit := c.Limit
+	if limit <= 0 {
+		limit = DefaultGuessLimit
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	for i := range out {
+		out[i].Hits[0].Rank = i
+	}
+	return out, nil
+}
+
+// labels makes every form of one name we think is worth trying, with its prior.
+func (c TLD) labels(name string) map[string]float64 {
+	out := map[string]float64{}
+	add := func(l string, prior float64) {
+		if prior > out[l] {
+			out[l] = prior
+		}
+	}
+	lower := strings.ToLower(name)
+	// "&" and "+" get stripped by cleaning, try them as "and" too. Marked so cleaning can't take them, that "and" is
+	// part of the name ("smithandjones") where a spelt out one is usually just filler.
+	const amp = "xampersandx"
+	variants := map[string]float64{lower: 1}
+	if strings.ContainsAny(lower, "&+") {
+		variants[strings.NewReplacer("&", " "+amp+" ", "+", " "+amp+" ").Replace(lower)] = 0.9
+	}
+	for v, vp := range variants {
+		words := strings.Fields(c.Jurisdiction.Clean(v))
+		if len(words) == 0 {
+			continue
+		}
+		// "smith & co" is smith once the co is cleaned off, not smithand.
+		for len(words) > 0 && (words[0] == amp || isConnective(words[0])) {
+			words = words[1:]
+		}
+		for len(words) > 0 && (words[len(words)-1] == amp || isConnective(words[len(words)-1])) {
+			words = words[:len(words)-1]
+		}
+		if len(words) == 0 {
+			continue
+		}
+		fromAmp := map[int]bool{}
+		for i, w := range words {
+			if w == amp {
+				words[i] = "and"
+				fromAmp[i] = true
+			}
+		}
+		add(strings.Join(words, ""), vp)
+		if len(words) > 1 {
+			add(strings.Join(words, "-"), vp*0.5)
+			add(words[0], vp*0.3)
+			if len(words) > 2 && !isConnective(words[1]) {
+				add(words[0]+words[1], vp*0.35)
+			}
+			acronym := ""
+			for _, w := range words {
+				acronym += w[:1]
+			}
+			add(acronym, vp*0.25)
+		}
+		specific := []string{}
+		for i, w := range words {
+			if !isGeneric(w) || fromAmp[i] {
+				specific = append(specific, w)
+			}
+		}
+		if len(specific) > 0 && len(specific) < len(words) {
+			add(strings.Join(specific, ""), vp*0.8)
+			if len(specific) > 1 {
+				add(strings.Join(specific, "-"), vp*0.4)
+			}
+		}
+	}
+	return out
+}
+
+func isConnective(w string) bool {
+	for _, c := range Connectives {
+		if w == c {
+			return true
+		}
+	}
+	return false
+}
+
+func isGeneric(w string) bool {
+	for _, g := range GenericWords {
+		if w == g {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package sources
+
+import "testing"
+
+func TestTLDLabels(t *testing.T) {
+	tld := TLD{Jurisdiction: LookupJurisdiction("gb")}
+	for name, want := range map[string][]string{
+		"Smith & Jones Holdings Ltd": {"smithandjones", "smithjones", "smithandjonesholdings", "smith"},
+		"Smith and Jones Holdings":   {"smithjones", "smithandjonesholdings"},
+		"Bank of Acme":               {"bankofacme", "bankacme"},
+		"Smith & Co":                 {"smith"},
+	} {
+		labels := tld.labels(name)
+		for _, l := range want {
+			if labels[l] == 0 {
+				t.Errorf("%s: no %s in %v", name, l, labels)
+			}
+		}
+		for _, l := range []string{"smithand", "bankof", "and", ""} {
+			if _, ok := labels[l]; ok {
+				t.Errorf("%s: guessed %q", name, l)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"github.com/ip-rw/rank/pkg/util"
+	"regexp"
+	"strings"
+)
+
+// Jurisdiction holds the country specific bits of candidate generation.
+type Jurisdiction struct {
+	Code      string
+	Countries []string // how RegisteredAddress.Country spells it
+	TLDs      []string // in order of preference
+	Locale    string   // duckduckgo kl= region
+	Suffixes  []string // legal form words to drop from names
+	Wikidata  string   // the country's item, what wikidata's P17 points at
+	stop      *regexp.Regexp
+}
+
+var jurisdictions = map[string]*Jurisdiction{
+	"gb": {Countries: []string{"united kingdom", "uk", "england", "wales", "scotland", "northern ireland", "great britain", "england and wales"},
+		TLDs: []string{".co.uk", ".com", ".uk"}, Locale: "uk-en", Suffixes: []string{"limited", "ltd", "plc", "llp", "lp", "cic"}, Wikidata: "Q145"},
+	"ie": {Countries: []string{"ireland", "republic of ireland", "eire"},
+		TLDs: []string{".ie", ".com"}, Locale: "ie-en", Suffixes: []string{"limited", "ltd", "dac", "clg", "plc", "teoranta", "teo", "uc", "ulc"}, Wikidata: "Q27"},
+	"us": {Countries: []string{"united states", "usa", "us", "united states of america"},
+		TLDs: []string{".com", ".us", ".net"}, Locale: "us-en", Suffixes: []string{"inc", "incorporated", "llc", "corp", "corporation", "co", "lp", "llp", "pc"}, Wikidata: "Q30"},
+	"ca": {Countries: []string{"canada"},
+		TLDs: []string{".ca", ".com"}, Locale: "ca-en", Suffixes: []string{"inc", "ltd", "limited", "corp", "corporation", "ltee", "ulc"}, Wikidata: "Q16"},
+	"de": {Countries: []string{"germany", "deutschland"},
+		TLDs: []string{".de", ".com"}, Locale: "de-de", Suffixes: []string{"gmbh", "mbh", "ag", "kg", "kgaa", "ug", "ohg", "ev", "e v", "co kg"}, Wikidata: "Q183"},
+	"at": {Countries: []string{"austria", "osterreich"},
+		TLDs: []string{".at", ".com"}, Locale: "at-de", Suffixes: []string{"gmbh", "ag", "kg", "og"}, Wikidata: "Q40"},
+	"ch": {Countries: []string{"switzerland", "schweiz", "suisse"},
+		TLDs: []string{".ch", ".com"}, Locale: "ch-de", Suffixes: []string{"ag", "gmbh", "sa", "sarl", "sagl"}, Wikidata: "Q39"},
+	"fr": {Countries: []string{"france"},
+		TLDs: []string{".fr", ".com"}, Locale: "fr-fr", Suffixes: []string{"sarl", "sa", "sas", "sasu", "eurl", "sci", "snc"}, Wikidata: "Q142"},
+	"be": {Countries: []string{"belgium", "belgique", "belgie"},
+		TLDs: []string{".be", ".com"}, Locale: "be-nl", Suffixes: []string{"bv", "nv", "bvba", "sprl", "srl", "sa", "cv"}, Wikidata: "Q31"},
+	"nl": {Countries: []string{"netherlands", "the netherlands", "nederland", "holland"},
+		TLDs: []string{".nl", ".com"}, Locale: "nl-nl", Suffixes: []string{"bv", "b v", "nv", "n v", "vof", "cv"}, Wikidata: "Q55"},
+	"lu": {Countries: []string{"luxembourg"},
+		TLDs: []string{".lu", ".com"}, Locale: "fr-fr", Suffixes: []string{"sarl", "sa", "scs", "sca"}, Wikidata: "Q32"},
+	"es": {Countries: []string{"spain", "espana"},
+		TLDs: []string{".es", ".com"}, Locale: "es-es", Suffixes: []string{"sl", "sa", "slu", "sociedad limitada", "sociedad anonima"}, Wikidata: "Q29"},
+	"it": {Countries: []string{"italy", "italia"},
+		TLDs: []string{".it", ".com"}, Locale: "it-it", Suffixes: []string{"srl", "spa", "sas", "snc"}, Wikidata: "Q38"},
+	"se": {Countries: []string{"sweden", "sverige"},
+		TLDs: []string{".se", ".com"}, Locale: "se-sv", Suffixes: []string{"ab", "hb", "kb"}, Wikidata: "Q34"},
+	"dk": {Countries: []string{"denmark", "danmark"},
+		TLDs: []string{".dk", ".com"}, Locale: "dk-da", Suffixes: []string{"aps", "as", "a s", "ivs"}, Wikidata: "Q35"},
+	"no": {Countries: []string{"norway", "norge"},
+		TLDs: []string{".no", ".com"}, Locale: "no-no", Suffixes: []string{"as", "asa", "ans"}, Wikidata: "Q20"},
+	"fi": {Countries: []string{"finland", "suomi"},
+		TLDs: []string{".fi", ".com"}, Locale: "fi-fi", Suffixes: []string{"oy", "oyj", "ab"}, Wikidata: "Q33"},
+	"au": {Countries: []string{"australia"},
+		TLDs: []string{".com.au", ".com", ".au"}, Locale: "au-en", Suffixes: []string{"pty", "ltd", "limited", "pty ltd"}, Wikidata: "Q408"},
+	"nz": {Countries: []string{"new zealand"},
+		TLDs: []string{".co.nz", ".nz", ".com"}, Locale: "nz-en", Suffixes: []string{"limited", "ltd"}, Wikidata: "Q664"},
+	"in": {Countries: []string{"india"},
+		TLDs: []string{".in", ".co.in", ".com"}, Locale: "in-en", Suffixes: []string{"pvt", "private", "limited", "ltd", "llp"}, Wikidata: "Q668"},
+	"za": {Countries: []string{"south africa"},
+		TLDs: []string{".co.za", ".com"}, Locale: "za-en", Suffixes: []string{"pty", "ltd", "limited", "cc", "npc", "soc"}, Wikidata: "Q258"},
+	"sg": {Countries: []string{"singapore"},
+		TLDs: []string{".com.sg", ".sg", ".com"}, Locale: "sg-en", Suffixes: []string{"pte", "ltd", "limited", "llp"}, Wikidata: "Q334"},
+	"hk": {Countries: []string{"hong kong"},
+		TLDs: []string{".com.hk", ".hk", ".com"}, Locale: "hk-tzh", Suffixes: []string{"limited", "ltd"}, Wikidata: "Q8646"},
+}
+
+// Default is what we fall back on when we can't work out where a company is registered.
+var Default = &Jurisdiction{Code: "", TLDs: []string{".com"}, Locale: "wt-wt"}
+
+func init() {
+	for code, j := range jurisdictions {
+		j.Code = code
+		words := make([]string, 0, len(j.Suffixes))
+		for _, s := range j.Suffixes {
+			words = append(words, regexp.QuoteMeta(s))
+		}
+		j.stop = regexp.MustCompile(`\b(` + strings.Join(words, "|") + `)\b`)
+	}
+}
+
+// LookupJurisdiction accepts opencorporates style codes, "us_de" is treated as "us".
+func LookupJurisdiction(code string) *Jurisdiction {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.Index(code, "_"); i > 0 {
+		code = code[:i]
+	}
+	if code == "uk" {
+		code = "gb"
+	}
+	if j, ok := jurisdictions[code]; ok {
+		return j
+	}
+	return Default
+}
+
+// JurisdictionFor works it out from the company's jurisdiction code, then the country in its address.
+func JurisdictionFor(c *util.Company) *Jurisdiction {
+	if j := LookupJurisdiction(c.JurisdictionCode); j != Default {
+		return j
+	}
+	country := strings.ToLower(strings.TrimSpace(c.RegisteredAddress.Country))
+	for _, j := range jurisdictions {
+		for _, name := range j.Countries {
+			if name == country {
+				return j
+			}
+		}
+	}
+	return Default
+}
+
+// Clean is CleanCompanyName with the jurisdiction's legal form suffixes removed as well.
+func (j *Jurisdiction) Clean(company string) string {
+	cleaned := CleanCompanyName(company)
+	if j == nil || j.stop == nil {
+		return cleaned
+	}
+	return strings.TrimSpace(ws.ReplaceAllString(j.stop.ReplaceAllString(cleaned, ""), " "))
+}
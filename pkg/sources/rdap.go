@@ -0,0 +1,250 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/levigross/grequests"
+	"github.com/sirupsen/logrus"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RDAPURL redirects every domain lookup to the registry that's authoritative for it.
+const RDAPURL = "https://rdap.org/"
+
+func init() {
+	// off by default, hardly any registry allows searching by registrant.
+	Register("RDAP", "{{.Name}}", false, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return RDAP{BaseURL: opts["url"]}
+	})
+}
+
+// RDAP searches an RDAP server for domains by registrant name, using the reverse search extension
+// (draft-ietf-regext-rdap-reverse-search) which only some servers permit. BaseURL has to be that server.
+type RDAP struct {
+	BaseURL string
+}
+
+func (r RDAP) Name() string {
+	return "RDAP"
+}
+
+type rdapEntity struct {
+	Roles     []string      `json:"roles"`
+	VCard     []interface{} `json:"vcardArray"`
+	Entities  []rdapEntity  `json:"entities"`
+	PublicIDs []struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	} `json:"publicIds"`
+}
+
+type rdapDomain struct {
+	LDHName  string       `json:"ldhName"`
+	Entities []rdapEntity `json:"entities"`
+	Events   []struct {
+		Action string `json:"eventAction"`
+		Date   string `json:"eventDate"`
+	} `json:"events"`
+}
+
+func (r RDAP) Lookup(ctx context.Context, company string) ([]*Candidate, error) {
+	if r.BaseURL == "" {
+		return nil, errors.New("rdap search needs a server url")
+	}
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Headers = map[string]string{"Accept": "application/rdap+json"}
+	ro.Params = map[string]string{"fn": company, "role": "registrant"}
+	res, err := grequests.Get(strings.TrimRight(r.BaseURL, "/")+"/domains/reverse_search/entity", ro)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Ok {
+		return nil, fmt.Errorf("rdap search returned %d", res.StatusCode)
+	}
+	var results struct {
+		Domains []rdapDomain `json:"domainSearchResults"`
+	}
+	if err := res.JSON(&results); err != nil {
+		return nil, err
+	}
+	var out []*Candidate
+	for i, d := range results.Domains {
+		if d.LDHName == "" {
+			continue
+		}
+		c := NewCandidate("http://"+strings.ToLower(d.LDHName), Hit{Source: r.Name(), Rank: i, Query: company})
+		c.Registration = d.registration()
+		out = append(out, c)
+	}
+	return MergeCandidates(out), nil
+}
+
+// Registration is what the domain's registry will tell us about who registered it and when.
+type Registration struct {
+	Registrant string
+	Registrar  string
+	Created    time.Time
+}
+
+func (d *rdapDomain) registration() *Registration {
+	reg := &Registration{}
+	var walk func([]rdapEntity)
+	walk = func(entities []rdapEntity) {
+		for _, e := range entities {
+			for _, role := range e.Roles {
+				switch role {
+				case "registrant":
+					if reg.Registrant == "" {
+						reg.Registrant = e.name()
+					}
+				case "registrar":
+					if reg.Registrar == "" {
+						reg.Registrar = e.name()
+					}
+				}
+			}
+			walk(e.Entities)
+		}
+	}
+	walk(d.Entities)
+	for _, ev := range d.Events {
+		if ev.Action == "registration" {
+			if t, err := time.Parse(time.RFC3339, ev.Date); err == nil {
+				reg.Created = t
+			}
+		}
+	}
+	return reg
+}
+
+// name prefers the vcard org over fn, registrants are companies more often than not.
+func (e *rdapEntity) name() string {
+	if len(e.VCard) < 2 {
+		return ""
+	}
+	props, _ := e.VCard[1].([]interface{})
+	var fn, org string
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if !ok || len(prop) < 4 {
+			continue
+		}
+		name, _ := prop[0].(string)
+		var value string
+		switch v := prop[3].(type) {
+		case string:
+			value = v
+		case []interface{}:
+			if len(v) > 0 {
+				value, _ = v[0].(string)
+			}
+		}
+		switch name {
+		case "fn":
+			fn = value
+		case "org":
+			org = value
+		}
+	}
+	if org != "" {
+		return org
+	}
+	return fn
+}
+
+// LookupRegistration asks an RDAP server (RDAPURL if base is empty) about one domain.
+func LookupRegistration(ctx context.Context, base, domain string) (*Registration, error) {
+	if base == "" {
+		base = RDAPURL
+	}
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Headers = map[string]string{"Accept": "application/rdap+json"}
+	res, err := grequests.Get(strings.TrimRight(base, "/")+"/domain/"+url.PathEscape(domain), ro)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Ok {
+		return nil, fmt.Errorf("rdap returned %d for %s", res.StatusCode, domain)
+	}
+	var d rdapDomain
+	if err := res.JSON(&d); err != nil {
+		return nil, err
+	}
+	return d.registration(), nil
+}
+
+// EnrichRegistrations attaches registration data to every candidate that doesn't have it yet, one lookup per
+// registrable domain. Domains the registry won't tell us about are left alone.
+func EnrichRegistrations(ctx context.Context, base string, cands []*Candidate) {
+	byDomain := map[string][]*Candidate{}
+	for _, c := range cands {
+		if c.Registration == nil && c.Domain != "" {
+			byDomain[c.Domain] = append(byDomain[c.Domain], c)
+		}
+	}
+	var (
+		wg  = sync.WaitGroup{}
+		sem = make(chan struct{}, 8)
+	)
+	for domain, cs := range byDomain {
+		wg.Add(1)
+		go func(domain string, cs []*Candidate) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			rctx, cancel := context.WithTimeout(ctx, SourceTimeout)
+			defer cancel()
+			reg, err := LookupRegistration(rctx, base, domain)
+			if err != nil {
+				logrus.WithError(err).WithField("domain", domain).Debug("rdap lookup failed")
+				return
+			}
+			for _, c := range cs {
+				c.Registration = reg
+			}
+		}(domain, cs)
+	}
+	wg.Wait()
+}
+
+var (
+	// RegistrantBonus is added when the registrant is the company.
+	RegistrantBonus = 0.2
+	// LateRegistrationPenalty is taken off per year a domain was registered after the company was incorporated,
+	// past LateRegistrationGrace, up to MaxLateRegistrationPenalty.
+	LateRegistrationPenalty    = 0.02
+	LateRegistrationGrace      = 2 * 365 * 24 * time.Hour
+	MaxLateRegistrationPenalty = 0.2
+)
+
+// Adjustment is how much a candidate's score should move given its registration. A registrant that matches the
+// company is rewarded, a domain registered long after incorporation is penalised a little.
+func (r *Registration) Adjustment(c *util.Company) float64 {
+	if r == nil {
+		return 0
+	}
+	adj := 0.0
+	if r.Registrant != "" {
+		registrant, name := util.NormaliseCompanyName(r.Registrant), util.NormaliseCompanyName(c.Name)
+		if registrant != "" && name != "" && (registrant == name || strings.Contains(registrant, name)) {
+			adj += RegistrantBonus
+		}
+	}
+	if inc, err := time.Parse("2006-01-02", c.IncorporationDate); err == nil && !r.Created.IsZero() {
+		if late := r.Created.Sub(inc) - LateRegistrationGrace; late > 0 {
+			penalty := late.Hours() / (365 * 24) * LateRegistrationPenalty
+			if penalty > MaxLateRegistrationPenalty {
+				penalty = MaxLateRegistrationPenalty
+			}
+			adj -= penalty
+		}
+	}
+	return adj
+}
@@ -0,0 +1,135 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ip-rw/rank/pkg/util"
+)
+
+// acme.co.uk as nominet answers for it, the registrant with an org and an fn, the registrar nested under an abuse
+// contact.
+const rdapAcme = `{"objectClassName":"domain","ldhName":"ACME.CO.UK","status":["active"],
+	"entities":[
+		{"objectClassName":"entity","roles":["registrant"],"vcardArray":["vcard",[["version",{},"text","4.0"],
+			["fn",{},"text","Jane Smith"],["org",{},"text","Acme Widgets Ltd"]]]},
+		{"objectClassName":"entity","roles":["technical"],"entities":[
+			{"objectClassName":"entity","roles":["registrar"],"vcardArray":["vcard",[["version",{},"text","4.0"],["fn",{},"text","Example Registrar Ltd [Tag = EXAMPLE]"]]]}]}],
+	"events":[{"eventAction":"registration","eventDate":"1999-03-01T12:00:00Z"},{"eventAction":"last changed","eventDate":"2023-05-05T09:00:00Z"}]}`
+
+func rdapServer(t *testing.T) (*httptest.Server, *sync.Map) {
+	var asked sync.Map
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/rdap+json" {
+			t.Errorf("%s asked for %s", r.URL, r.Header.Get("Accept"))
+		}
+		n, _ := asked.LoadOrStore(r.URL.Path, new(int))
+		*n.(*int)++
+		switch r.URL.Path {
+		case "/domain/acme.co.uk":
+			fmt.Fprint(w, rdapAcme)
+		case "/domain/busy.com":
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, `{"errorCode":429,"title":"Too Many Requests"}`, http.StatusTooManyRequests)
+		case "/domains/reverse_search/entity":
+			if q := r.URL.Query(); q.Get("fn") != "Acme Widgets Ltd" || q.Get("role") != "registrant" {
+				t.Errorf("unexpected search %s", r.URL.RawQuery)
+			}
+			fmt.Fprintf(w, `{"domainSearchResults":[%s,{"ldhName":""},{"ldhName":"acme-widgets.com","events":[]},{"ldhName":"www.acme.co.uk"}]}`, rdapAcme)
+		default:
+			http.Error(w, `{"errorCode":404,"title":"Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, &asked
+}
+
+func TestLookupRegistration(t *testing.T) {
+	s, _ := rdapServer(t)
+	reg, err := LookupRegistration(context.Background(), s.URL+"/", "acme.co.uk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Registration{Registrant: "Acme Widgets Ltd", Registrar: "Example Registrar Ltd [Tag = EXAMPLE]", Created: time.Date(1999, 3, 1, 12, 0, 0, 0, time.UTC)}
+	if *reg != want {
+		t.Errorf("got %+v, want %+v", *reg, want)
+	}
+	for _, domain := range []string{"gone.com", "busy.com"} {
+		if _, err := LookupRegistration(context.Background(), s.URL, domain); err == nil {
+			t.Errorf("expected an error for %s", domain)
+		}
+	}
+}
+
+// domains the registry won't talk about, missing or because we've asked too often, are left without a registration.
+func TestEnrichRegistrations(t *testing.T) {
+	s, asked := rdapServer(t)
+	cands := []*Candidate{
+		NewCandidate("https://acme.co.uk", Hit{Source: "TLD"}),
+		NewCandidate("https://www.acme.co.uk/about", Hit{Source: "Bing"}),
+		NewCandidate("https://gone.com", Hit{Source: "TLD"}),
+		NewCandidate("https://busy.com", Hit{Source: "TLD"}),
+	}
+	known := &Registration{Registrant: "Someone Else"}
+	cands = append(cands, NewCandidate("https://known.com", Hit{Source: "RDAP"}))
+	cands[4].Registration = known
+
+	EnrichRegistrations(context.Background(), s.URL, cands)
+	if cands[0].Registration == nil || cands[0].Registration != cands[1].Registration || cands[0].Registration.Registrant != "Acme Widgets Ltd" {
+		t.Errorf("acme.co.uk got %+v and %+v", cands[0].Registration, cands[1].Registration)
+	}
+	if cands[2].Registration != nil || cands[3].Registration != nil {
+		t.Errorf("got registrations %+v, %+v for domains the registry didn't answer for", cands[2].Registration, cands[3].Registration)
+	}
+	if cands[4].Registration != known {
+		t.Errorf("replaced a registration we already had")
+	}
+	for path, want := range map[string]int{"/domain/acme.co.uk": 1, "/domain/busy.com": 1, "/domain/known.com": 0} {
+		got := 0
+		if n, ok := asked.Load(path); ok {
+			got = *n.(*int)
+		}
+		if got != want {
+			t.Errorf("asked for %s %d times, want %d", path, got, want)
+		}
+	}
+}
+
+func TestRDAPSearch(t *testing.T) {
+	s, _ := rdapServer(t)
+	cands, err := RDAP{BaseURL: s.URL}.Lookup(context.Background(), "Acme Widgets Ltd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 2 || cands[0].Domain != "acme.co.uk" || cands[1].Domain != "acme-widgets.com" {
+		t.Fatalf("got %v", cands)
+	}
+	if len(cands[0].Hits) != 2 || cands[0].Registration.Registrant != "Acme Widgets Ltd" || cands[1].Hits[0].Rank != 2 {
+		t.Errorf("got hits %+v, registration %+v", cands[0].Hits, cands[0].Registration)
+	}
+	if _, err := (RDAP{}).Lookup(context.Background(), "acme"); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
+
+func TestRegistrationAdjustment(t *testing.T) {
+	company := &util.Company{Name: "ACME WIDGETS LIMITED", IncorporationDate: "1999-01-01"}
+	for _, tt := range []struct {
+		reg  *Registration
+		want float64
+	}{
+		{nil, 0},
+		{&Registration{Registrant: "Acme Widgets Ltd", Created: time.Date(1999, 3, 1, 0, 0, 0, 0, time.UTC)}, RegistrantBonus},
+		{&Registration{Registrant: "Domain Admin", Created: time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC)}, -0.1},
+		{&Registration{Created: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}, -MaxLateRegistrationPenalty},
+	} {
+		if got := tt.reg.Adjustment(company); got < tt.want-0.001 || got > tt.want+0.001 {
+			t.Errorf("%+v: got %v, want %v", tt.reg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ip-rw/rank/pkg/util"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Factory builds a source for one company, opts come straight from the source's config entry.
+type Factory func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource
+
+type registration struct {
+	factory Factory
+	query   string
+	enabled bool
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]*registration{}
+)
+
+// Register makes a source available by name. query is the default template it gets asked, and enabled says whether
+// it runs when there's no config.
+func Register(name, query string, enabled bool, f Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry[name] = &registration{factory: f, query: query, enabled: enabled}
+}
+
+// Registered lists every source name, sorted.
+func Registered() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return sortedNames()
+}
+
+// SourceConfig turns on one source. Query is a text/template over QueryData, empty means the source's default.
+type SourceConfig struct {
+	Name    string            `json:"name"`
+	Query   string            `json:"query,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Config picks the sources FindPossibleDomains runs, in order.
+type Config struct {
+	Sources []SourceConfig `json:"sources"`
+}
+
+// QueryData is what query templates get to work with, e.g. `{{.Name}} {{.Postcode}}`.
+type QueryData struct {
+	Name          string
+	Number        string
+	Jurisdiction  string
+	Postcode      string
+	Locality      string
+	Region        string
+	Country       string
+	PreviousNames []string
+}
+
+func NewQueryData(c *util.Company) *QueryData {
+	return &QueryData{
+		Name:          c.Name,
+		Number:        c.CompanyNumber,
+		Jurisdiction:  c.JurisdictionCode,
+		Postcode:      c.RegisteredAddress.PostalCode,
+		Locality:      c.RegisteredAddress.Locality,
+		Region:        c.RegisteredAddress.Region,
+		Country:       c.RegisteredAddress.Country,
+		PreviousNames: c.PreviousNameList(),
+	}
+}
+
+// DefaultConfig runs every source registered as enabled, with its default query.
+func DefaultConfig() *Config {
+	cfg := &Config{}
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	for _, name := range sortedNames() {
+		if registry[name].enabled {
+			cfg.Sources = append(cfg.Sources, SourceConfig{Name: name})
+		}
+	}
+	return cfg
+}
+
+func sortedNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadConfig reads a json config, checking every source exists and every query parses.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, sc := range cfg.Sources {
+		if _, _, err := sc.build(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return &cfg, nil
+}
+
+func (sc *SourceConfig) build() (Factory, *template.Template, error) {
+	registryLock.RLock()
+	reg, ok := registry[sc.Name]
+	registryLock.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown source '%s', have %s", sc.Name, strings.Join(Registered(), ", "))
+	}
+	query := sc.Query
+	if query == "" {
+		query = reg.query
+	}
+	t, err := template.New(sc.Name).Option("missingkey=zero").Parse(query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source '%s': %w", sc.Name, err)
+	}
+	return reg.factory, t, nil
+}
+
+// Render fills in the source's query template for a company.
+func (sc *SourceConfig) Render(c *util.Company) (string, error) {
+	_, t, err := sc.build()
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, NewQueryData(c)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(ws.ReplaceAllString(b.String(), " ")), nil
+}
@@ -0,0 +1,270 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/levigross/grequests"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	BingURL  = "https://www.bing.com/search"
+	BraveURL = "https://api.search.brave.com/res/v1/web/search"
+)
+
+func init() {
+	Register("Bing", `{{.Name}} "{{.Number}}"`, true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return Bing{Jurisdiction: j, BaseURL: opts["url"], Limit: optInt(opts, "limit", 0)}
+	})
+	// these two need setting up, a key for brave and a server for searxng.
+	Register("Brave", `{{.Name}} {{.Locality}}`, false, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		key := opts["key"]
+		if key == "" {
+			key = os.Getenv("BRAVE_API_KEY")
+		}
+		return Brave{Jurisdiction: j, BaseURL: opts["url"], APIKey: key, Limit: optInt(opts, "limit", 0)}
+	})
+	Register("SearXNG", `{{.Name}} {{.Locality}}`, false, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return SearXNG{Jurisdiction: j, BaseURL: opts["url"], Limit: optInt(opts, "limit", 0)}
+	})
+}
+
+// SearchResult is one organic result from a search engine, before we decide what it tells us.
+type SearchResult struct {
+	URL     string
+	Title   string
+	Snippet string
+}
+
+// DefaultSearchLimit is how many results of any one engine we look at.
+var DefaultSearchLimit = 20
+
+// SearchCandidates is the shared end of every search source, turning the first limit results into candidates. Every
+// result becomes its registrable domain, a deep link is kept as a crawl seed and the result itself as evidence.
+func SearchCandidates(source, query string, results []SearchResult, limit int) []*Candidate {
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	var out []*Candidate
+	for i, r := range results {
+		if c := ResultCandidate(r, Hit{Source: source, Rank: i, Query: query}); c != nil {
+			out = append(out, c)
+		}
+	}
+	return MergeCandidates(out)
+}
+
+// ResultCandidate is one result's candidate, nil if the result isn't a web page.
+func ResultCandidate(r SearchResult, hit Hit) *Candidate {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	u.Fragment = ""
+	c := NewCandidate(u.Scheme+"://"+RegistrableDomain(u.Hostname()), hit)
+	if (u.Path != "/" && u.Path != "") || u.RawQuery != "" || u.Hostname() != c.Domain {
+		c.Seeds = []string{u.String()}
+	}
+	c.Evidence = []Evidence{{Source: hit.Source, URL: r.URL, Title: r.Title, Snippet: r.Snippet}}
+	return c
+}
+
+func optInt(opts map[string]string, key string, def int) int {
+	if v, err := strconv.Atoi(opts[key]); err == nil {
+		return v
+	}
+	return def
+}
+
+// country is the jurisdiction as an ISO 3166 code, what bing and brave want.
+func country(j *Jurisdiction) string {
+	if j == nil {
+		return ""
+	}
+	return strings.ToUpper(j.Code)
+}
+
+// Bing scrapes the html results page.
+type Bing struct {
+	Jurisdiction *Jurisdiction
+	BaseURL      string
+	Limit        int
+}
+
+func (b Bing) Name() string {
+	return "Bing"
+}
+
+func (b Bing) Lookup(ctx context.Context, q string) ([]*Candidate, error) {
+	base := b.BaseURL
+	if base == "" {
+		base = BingURL
+	}
+	query := b.Jurisdiction.Clean(q)
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Params = map[string]string{"q": query, "count": "50"}
+	if cc := country(b.Jurisdiction); cc != "" {
+		ro.Params["cc"] = cc
+	}
+	r, err := grequests.Get(base, ro)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, fmt.Errorf("bing returned %d", r.StatusCode)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	doc.Find("li.b_algo").Each(func(i int, s *goquery.Selection) {
+		a := s.Find("h2 a").First()
+		link, exists := a.Attr("href")
+		if !exists {
+			return
+		}
+		results = append(results, SearchResult{
+			URL:     bingTarget(link),
+			Title:   strings.TrimSpace(a.Text()),
+			Snippet: strings.TrimSpace(s.Find(".b_caption p").First().Text()),
+		})
+	})
+	return SearchCandidates(b.Name(), query, results, b.Limit), nil
+}
+
+// bingTarget unwraps bing's click tracking links, bing.com/ck/a?...&u=a1<base64 url>.
+func bingTarget(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || !strings.HasSuffix(u.Hostname(), "bing.com") {
+		return link
+	}
+	enc := strings.TrimPrefix(u.Query().Get("u"), "a1")
+	if enc == "" {
+		return link
+	}
+	dec, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(enc, "="))
+	if err != nil {
+		return link
+	}
+	return string(dec)
+}
+
+// Brave uses the Brave Search API, which needs a subscription token.
+type Brave struct {
+	Jurisdiction *Jurisdiction
+	BaseURL      string
+	APIKey       string
+	Limit        int
+}
+
+func (b Brave) Name() string {
+	return "Brave"
+}
+
+func (b Brave) Lookup(ctx context.Context, q string) ([]*Candidate, error) {
+	if b.APIKey == "" {
+		return nil, errors.New("brave needs an api key, set BRAVE_API_KEY or the key option")
+	}
+	base := b.BaseURL
+	if base == "" {
+		base = BraveURL
+	}
+	query := b.Jurisdiction.Clean(q)
+	limit := b.Limit
+	if limit <= 0 || limit > 20 {
+		limit = 20 // most the api gives out per page
+	}
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Headers = map[string]string{"Accept": "application/json", "X-Subscription-Token": b.APIKey}
+	ro.Params = map[string]string{"q": query, "count": strconv.Itoa(limit)}
+	if cc := country(b.Jurisdiction); cc != "" {
+		ro.Params["country"] = cc
+	}
+	r, err := grequests.Get(base, ro)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, fmt.Errorf("brave returned %d", r.StatusCode)
+	}
+	var res struct {
+		Web struct {
+			Results []struct {
+				URL         string `json:"url"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := r.JSON(&res); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(res.Web.Results))
+	for _, wr := range res.Web.Results {
+		results = append(results, SearchResult{URL: wr.URL, Title: wr.Title, Snippet: wr.Description})
+	}
+	return SearchCandidates(b.Name(), query, results, limit), nil
+}
+
+// SearXNG queries a (probably self hosted) SearXNG instance's json api, which has to be enabled in its settings.
+type SearXNG struct {
+	Jurisdiction *Jurisdiction
+	BaseURL      string
+	Limit        int
+}
+
+func (s SearXNG) Name() string {
+	return "SearXNG"
+}
+
+func (s SearXNG) Lookup(ctx context.Context, q string) ([]*Candidate, error) {
+	if s.BaseURL == "" {
+		return nil, errors.New("searxng needs a server url")
+	}
+	query := s.Jurisdiction.Clean(q)
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Params = map[string]string{"q": query, "format": "json"}
+	if cc := country(s.Jurisdiction); cc != "" {
+		// duckduckgo's "uk-en" is searxng's "en-GB".
+		if parts := strings.SplitN(s.Jurisdiction.Locale, "-", 2); len(parts) == 2 {
+			ro.Params["language"] = parts[1] + "-" + cc
+		}
+	}
+	r, err := grequests.Get(strings.TrimRight(s.BaseURL, "/")+"/search", ro)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, fmt.Errorf("searxng returned %d", r.StatusCode)
+	}
+	var res struct {
+		Results []struct {
+			URL     string `json:"url"`
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := r.JSON(&res); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(res.Results))
+	for _, sr := range res.Results {
+		results = append(results, SearchResult{URL: sr.URL, Title: sr.Title, Snippet: sr.Content})
+	}
+	return SearchCandidates(s.Name(), query, results, s.Limit), nil
+}
@@ -0,0 +1,154 @@
+package sources
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recorded serves a results page from testdata, after check has had a look at the request.
+func recorded(t *testing.T, file string, check func(r *http.Request)) *httptest.Server {
+	page, err := ioutil.ReadFile("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		check(r)
+		w.Write(page)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+type result struct {
+	domain string
+	ranks  []int
+	seeds  []string
+}
+
+func results(cands []*Candidate) []result {
+	var out []result
+	for _, c := range cands {
+		r := result{domain: c.Domain, seeds: c.Seeds}
+		for _, h := range c.Hits {
+			r.ranks = append(r.ranks, h.Rank)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestBing(t *testing.T) {
+	s := recorded(t, "bing.html", func(r *http.Request) {
+		if q := r.URL.Query(); q.Get("q") != "acme widgets 01234567" || q.Get("cc") != "GB" || q.Get("count") != "50" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+	})
+	b := Bing{Jurisdiction: LookupJurisdiction("gb"), BaseURL: s.URL}
+	cands, err := b.Lookup(context.Background(), `Acme Widgets Ltd "01234567"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []result{
+		{"acmewidgets.co.uk", []int{0, 2}, []string{"https://www.acmewidgets.co.uk/about-us", "https://www.acmewidgets.co.uk/"}},
+		{"company-information.service.gov.uk", []int{1}, []string{"https://find-and-update.company-information.service.gov.uk/company/01234567"}},
+		{"endole.co.uk", []int{3}, []string{"https://www.endole.co.uk/company/01234567/acme-widgets-limited"}},
+	}
+	if got := results(cands); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if e := cands[0].Evidence[0]; e.Title != "About Us - Acme Widgets Ltd" || !strings.HasSuffix(e.Snippet, "since 1999.") {
+		t.Errorf("got evidence %+v", e)
+	}
+
+	b.Limit = 2
+	if cands, _ = b.Lookup(context.Background(), `Acme Widgets Ltd "01234567"`); len(cands) != 2 {
+		t.Errorf("limit 2 gave %d candidates", len(cands))
+	}
+}
+
+func TestBingTarget(t *testing.T) {
+	for link, want := range map[string]string{
+		"https://www.bing.com/ck/a?!&&p=x&u=a1aHR0cHM6Ly93d3cuYWNtZXdpZGdldHMuY28udWsvYWJvdXQ&ntb=1": "https://www.acmewidgets.co.uk/about",
+		// padded, which bing doesn't do, but we shouldn't mind.
+		"https://www.bing.com/ck/a?u=a1aHR0cHM6Ly9hY21lLmNvbS8%3D":   "https://acme.com/",
+		"https://www.bing.com/ck/a?p=x":                              "https://www.bing.com/ck/a?p=x",
+		"https://www.bing.com/ck/a?u=a1%25%25%25":                    "https://www.bing.com/ck/a?u=a1%25%25%25",
+		"https://www.acmewidgets.co.uk/?u=a1aHR0cHM6Ly9hY21lLmNvbS8": "https://www.acmewidgets.co.uk/?u=a1aHR0cHM6Ly9hY21lLmNvbS8",
+	} {
+		if got := bingTarget(link); got != want {
+			t.Errorf("bingTarget(%s) = %s, want %s", link, got, want)
+		}
+	}
+}
+
+func TestBrave(t *testing.T) {
+	s := recorded(t, "brave.json", func(r *http.Request) {
+		if r.Header.Get("X-Subscription-Token") != "secret" {
+			t.Errorf("no api key sent")
+		}
+		if q := r.URL.Query(); q.Get("q") != "acme widgets sheffield" || q.Get("country") != "GB" || q.Get("count") != "20" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+	})
+	b := Brave{Jurisdiction: LookupJurisdiction("gb"), BaseURL: s.URL, APIKey: "secret"}
+	cands, err := b.Lookup(context.Background(), "Acme Widgets Ltd Sheffield")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []result{
+		{"acmewidgets.co.uk", []int{0, 2}, []string{"https://www.acmewidgets.co.uk/", "https://www.acmewidgets.co.uk/contact"}},
+		{"yell.com", []int{1}, []string{"https://www.yell.com/biz/acme-widgets-ltd-sheffield-901234/"}},
+		{"widgetworld.com", []int{3}, nil},
+	}
+	if got := results(cands); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if _, err := (Brave{BaseURL: s.URL}).Lookup(context.Background(), "acme"); err == nil {
+		t.Error("expected an error without an api key")
+	}
+}
+
+func TestSearXNG(t *testing.T) {
+	s := recorded(t, "searxng.json", func(r *http.Request) {
+		if q := r.URL.Query(); r.URL.Path != "/search" || q.Get("q") != "acme widgets sheffield" || q.Get("format") != "json" || q.Get("language") != "en-GB" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	})
+	sx := SearXNG{Jurisdiction: LookupJurisdiction("gb"), BaseURL: s.URL + "/"}
+	cands, err := sx.Lookup(context.Background(), "Acme Widgets Ltd Sheffield")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []result{
+		{"acmewidgets.co.uk", []int{0}, []string{"https://www.acmewidgets.co.uk/"}},
+		{"linkedin.com", []int{1}, []string{"https://uk.linkedin.com/company/acme-widgets"}},
+	}
+	if got := results(cands); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if _, err := (SearXNG{}).Lookup(context.Background(), "acme"); err == nil {
+		t.Error("expected an error without a server")
+	}
+}
+
+// an engine that won't answer is an error, not no results.
+func TestSearchErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+	for _, src := range []DomainSource{
+		Bing{BaseURL: s.URL},
+		Brave{BaseURL: s.URL, APIKey: "secret"},
+		SearXNG{BaseURL: s.URL},
+	} {
+		if cands, err := src.Lookup(context.Background(), "acme"); err == nil || !strings.Contains(err.Error(), "429") {
+			t.Errorf("%s: got %v and %d candidates from a 429", src.Name(), err, len(cands))
+		}
+	}
+}
@@ -2,6 +2,7 @@ package sources
 
 import (
 	"bytes"
+	"context"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/ip-rw/rank/pkg/util"
 	"github.com/levigross/grequests"
@@ -21,14 +22,32 @@ var (
 	strip       = regexp.MustCompile(`[^a-zA-Z0-9]+`)
 	ws          = regexp.MustCompile(`\s+`)
 )
+func init() {
+	Register("DuckDuckGo", `{{.Name}} "{{.Number}}"`, true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return DuckDuckGo{Jurisdiction: j, Limit: optInt(opts, "limit", 0)}
+	})
+	Register("Clearbit", "{{.Name}}", true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return Clearbit{Jurisdiction: j}
+	})
+}
+
 type DuckDuckGo struct {
+	Jurisdiction *Jurisdiction
+	Limit        int
 }
 func (c DuckDuckGo) Name() string {
 	return "DuckDuckGo"
 }
 
-func (g DuckDuckGo) Lookup(q string) ([]string, error) {
-	r, err := grequests.Get("http://duckduckgo.com/html?kh=-1&kp=-2&kl=uk-en&q=" + url.QueryEscape(CleanCompanyName(q)), util.GetProxyRequestOptions())
+func (g DuckDuckGo) Lookup(ctx context.Context, q string) ([]*Candidate, error) {
+	locale := "uk-en"
+	if g.Jurisdiction != nil {
+		locale = g.Jurisdiction.Locale
+	}
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	query := g.Jurisdiction.Clean(q)
+	r, err := grequests.Get("http://duckduckgo.com/html?kh=-1&kp=-2&kl="+locale+"&q="+url.QueryEscape(query), ro)
 	if err != nil {
 		return nil, err
 	}
@@ -36,43 +55,32 @@ func (g DuckDuckGo) Lookup(q string) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	domains := make([]string, 0)
+	var results []SearchResult
 	doc.Find("a.result__a").Each(func(i int, s *goquery.Selection) {
 		link, exists := s.Attr("href")
 		if !exists {
 			return
 		}
-		u, _ := url.Parse(link)
-		link = u.Query().Get("uddg")
-		if link == "" {
+		u, err := url.Parse(link)
+		if err != nil {
 			return
 		}
-		u, _ = url.Parse(link)
-		if u.Host != "" && u.Path == "/" {
-			link = u.Scheme + "://" + u.Hostname()
-			domains = util.AppendUniq(domains, link)
+		if uddg := u.Query().Get("uddg"); uddg != "" {
+			link = uddg
 		}
+		results = append(results, SearchResult{
+			URL:     link,
+			Title:   strings.TrimSpace(s.Text()),
+			Snippet: strings.TrimSpace(s.Closest(".result").Find(".result__snippet").Text()),
+		})
 	})
-
-	return domains, nil
+	return SearchCandidates(g.Name(), query, results, g.Limit), nil
 }
-type TLD struct{}
 
-func (c TLD) Name() string {
-	return "TLD"
-}
-func (c TLD) Lookup(company string) ([]string, error) {
-	out := []string{}
-	cc := CleanCompanyName(company)
-	for _, tld := range []string{".co.uk", ".com"} {
-		out = append(out, "http://"+strip.ReplaceAllString(cc, "") + tld)
-		out = append(out, "http://www."+strip.ReplaceAllString(cc, "") + tld)
-	}
-	return out, nil
+type Clearbit struct {
+	Jurisdiction *Jurisdiction
 }
 
-type Clearbit struct{}
-
 func (c Clearbit) Name() string {
 	return "Clearbit"
 }
@@ -83,9 +91,12 @@ func CleanCompanyName(company string) string {
 	return strings.TrimSpace(stop.ReplaceAllString(cleaned, ""))
 }
 
-func (c Clearbit) Lookup(company string) ([]string, error) {
-	rawUrl := "https://autocomplete.clearbit.com/v1/companies/suggest?query=" + url.QueryEscape(CleanCompanyName(company))
-	r, err := grequests.Get(rawUrl, util.GetProxyRequestOptions())
+func (c Clearbit) Lookup(ctx context.Context, company string) ([]*Candidate, error) {
+	query := c.Jurisdiction.Clean(company)
+	rawUrl := "https://autocomplete.clearbit.com/v1/companies/suggest?query=" + url.QueryEscape(query)
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	r, err := grequests.Get(rawUrl, ro)
 	if err != nil {
 		return nil, err
 	}
@@ -93,11 +104,9 @@ func (c Clearbit) Lookup(company string) ([]string, error) {
 	if err = r.JSON(&results); err != nil {
 		return nil, err
 	}
-	domains := make([]string, 0, len(results))
-	for _, c := range results {
-		domains = append(domains, "http://"+c.Domain)
-		domains = append(domains, "https://"+c.Domain)
-
+	domains := make([]*Candidate, 0, len(results))
+	for i, r := range results {
+		domains = append(domains, NewCandidate("https://"+r.Domain, Hit{Source: c.Name(), Rank: i, Query: query}))
 	}
-	return domains, nil
+	return MergeCandidates(domains), nil
 }
@@ -0,0 +1,261 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"github.com/ip-rw/rank/pkg/util"
+	"github.com/levigross/grequests"
+	"strings"
+)
+
+const WikidataURL = "https://www.wikidata.org/w/api.php"
+
+// the wikidata properties we look companies up by, and the one we're after.
+const (
+	PropCompaniesHouseID = "P2622"
+	PropLEI              = "P1278"
+	PropCountry          = "P17"
+	PropOfficialWebsite  = "P856"
+)
+
+func init() {
+	Register("Wikidata", "{{.Name}}", true, func(c *util.Company, j *Jurisdiction, opts map[string]string) DomainSource {
+		return Wikidata{Company: c, Jurisdiction: j, BaseURL: opts["url"], Limit: optInt(opts, "limit", 0)}
+	})
+}
+
+// Wikidata finds the company's item and returns its official website (P856), which for anything big enough to have
+// an item is about as authoritative as it gets. The item is found by, in order, the wikipedia article we were given,
+// its companies house number, its LEI and finally the query (its name) within its country. BaseURL is anything that
+// answers like the wikidata action api, which is also how to point it at a local stand-in.
+type Wikidata struct {
+	Company      *util.Company
+	Jurisdiction *Jurisdiction
+	BaseURL      string
+	Limit        int // items per way of finding them
+}
+
+type wdSnak struct {
+	SnakType  string `json:"snaktype"`
+	DataValue struct {
+		Value interface{} `json:"value"`
+	} `json:"datavalue"`
+}
+
+type wdEntity struct {
+	ID      string  `json:"id"`
+	Missing *string `json:"missing"`
+	Labels  map[string]struct {
+		Value string `json:"value"`
+	} `json:"labels"`
+	Claims map[string][]struct {
+		MainSnak wdSnak `json:"mainsnak"`
+		Rank     string `json:"rank"`
+	} `json:"claims"`
+}
+
+func (w Wikidata) Name() string {
+	return "Wikidata"
+}
+
+func (w Wikidata) Lookup(ctx context.Context, q string) ([]*Candidate, error) {
+	limit := w.Limit
+	if limit <= 0 {
+		limit = 5
+	}
+	// an identifier is as good as proof, a name is a guess.
+	type match struct {
+		how   string
+		ids   []string
+		score float64
+	}
+	var matches []match
+	if c := w.Company; c != nil {
+		if c.WikipediaID != "" {
+			ids, err := w.byTitle(ctx, c.WikipediaID)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, match{"enwiki:" + c.WikipediaID, ids, 1})
+		}
+		if number := companiesHouseNumber(c, w.Jurisdiction); number != "" {
+			how := "haswbstatement:" + PropCompaniesHouseID + "=" + number
+			ids, err := w.search(ctx, how, limit)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, match{how, ids, 1})
+		}
+		if lei := c.LEICode(); lei != "" {
+			how := "haswbstatement:" + PropLEI + "=" + strings.ToUpper(lei)
+			ids, err := w.search(ctx, how, limit)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, match{how, ids, 1})
+		}
+	}
+	if name := strings.TrimSpace(q); name != "" {
+		// free text matches anything, so only bother with items that have a website, in the right country.
+		how := strings.Replace(name, `"`, "", -1) + " haswbstatement:" + PropOfficialWebsite
+		if w.Jurisdiction != nil && w.Jurisdiction.Wikidata != "" {
+			how += " haswbstatement:" + PropCountry + "=" + w.Jurisdiction.Wikidata
+		}
+		ids, err := w.search(ctx, how, limit)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match{how, ids, 0.5})
+	}
+
+	var all []string
+	for _, m := range matches {
+		for _, id := range m.ids {
+			all = util.AppendUniq(all, id)
+		}
+	}
+	entities, err := w.entities(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Candidate
+	seen := map[string]bool{}
+	for _, m := range matches {
+		for _, id := range m.ids {
+			e, ok := entities[id]
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			for _, site := range e.websites() {
+				r := SearchResult{URL: site, Title: e.label(), Snippet: fmt.Sprintf("official website of %s", id)}
+				if c := ResultCandidate(r, Hit{Source: w.Name(), Rank: len(out), Query: m.how, Score: m.score}); c != nil {
+					out = append(out, c)
+				}
+			}
+		}
+	}
+	return MergeCandidates(out), nil
+}
+
+// companiesHouseNumber is the company's number the way wikidata has it, if companies house is its registry.
+func companiesHouseNumber(c *util.Company, j *Jurisdiction) string {
+	if j == nil || j.Code != "gb" {
+		return ""
+	}
+	number := strings.ToUpper(strings.TrimSpace(c.CompanyNumber))
+	if number != "" && strings.Trim(number, "0123456789") == "" && len(number) < 8 {
+		number = strings.Repeat("0", 8-len(number)) + number
+	}
+	return number
+}
+
+func (w Wikidata) get(ctx context.Context, params map[string]string, v interface{}) error {
+	base := w.BaseURL
+	if base == "" {
+		base = WikidataURL
+	}
+	params["format"] = "json"
+	ro := util.GetProxyRequestOptions()
+	ro.Context = ctx
+	ro.Params = params
+	// wikimedia turns away requests without a user agent that says who we are.
+	ro.UserAgent = "rank/0.1 (https://github.com/ip-rw/rank)"
+	r, err := grequests.Get(base, ro)
+	if err != nil {
+		return err
+	}
+	if !r.Ok {
+		return fmt.Errorf("wikidata returned %d", r.StatusCode)
+	}
+	return r.JSON(v)
+}
+
+// search runs a CirrusSearch query (haswbstatement: and all) and returns the item ids it found.
+func (w Wikidata) search(ctx context.Context, query string, limit int) ([]string, error) {
+	var res struct {
+		Query struct {
+			Search []struct {
+				Title string `json:"title"`
+			} `json:"search"`
+		} `json:"query"`
+	}
+	err := w.get(ctx, map[string]string{"action": "query", "list": "search", "srsearch": query, "srlimit": fmt.Sprint(limit)}, &res)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, s := range res.Query.Search {
+		ids = append(ids, s.Title)
+	}
+	return ids, nil
+}
+
+// byTitle finds the item behind an english wikipedia article.
+func (w Wikidata) byTitle(ctx context.Context, title string) ([]string, error) {
+	var res struct {
+		Entities map[string]wdEntity `json:"entities"`
+	}
+	params := map[string]string{"action": "wbgetentities", "sites": "enwiki", "titles": title, "props": "info"}
+	if err := w.get(ctx, params, &res); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range res.Entities {
+		if e.Missing == nil && e.ID != "" {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids, nil
+}
+
+// entities fetches the labels and claims of the given items, 50 at a time which is all the api allows.
+func (w Wikidata) entities(ctx context.Context, ids []string) (map[string]wdEntity, error) {
+	out := map[string]wdEntity{}
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > 50 {
+			n = 50
+		}
+		var res struct {
+			Entities map[string]wdEntity `json:"entities"`
+		}
+		params := map[string]string{"action": "wbgetentities", "ids": strings.Join(ids[:n], "|"), "props": "labels|claims", "languages": "en"}
+		if err := w.get(ctx, params, &res); err != nil {
+			return nil, err
+		}
+		for id, e := range res.Entities {
+			if e.Missing == nil {
+				out[id] = e
+			}
+		}
+		ids = ids[n:]
+	}
+	return out, nil
+}
+
+// websites are the item's official websites, preferred ones first and deprecated ones not at all.
+func (e wdEntity) websites() []string {
+	var preferred, normal []string
+	for _, claim := range e.Claims[PropOfficialWebsite] {
+		site, ok := claim.MainSnak.DataValue.Value.(string)
+		if !ok || claim.MainSnak.SnakType != "value" || site == "" {
+			continue
+		}
+		switch claim.Rank {
+		case "preferred":
+			preferred = append(preferred, site)
+		case "deprecated":
+		default:
+			normal = append(normal, site)
+		}
+	}
+	return append(preferred, normal...)
+}
+
+func (e wdEntity) label() string {
+	if l, ok := e.Labels["en"]; ok {
+		return l.Value
+	}
+	return e.ID
+}
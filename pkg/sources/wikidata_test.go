@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ip-rw/rank/pkg/util"
+)
+
+func wdItem(id, label string, sites ...string) string {
+	var claims []string
+	for _, s := range sites {
+		// "site rank"
+		parts := strings.Fields(s)
+		claims = append(claims, fmt.Sprintf(`{"mainsnak":{"snaktype":"value","datavalue":{"value":%q}},"rank":%q}`, parts[0], parts[1]))
+	}
+	return fmt.Sprintf(`%q:{"id":%q,"labels":{"en":{"value":%q}},"claims":{"P856":[%s]}}`, id, id, label, strings.Join(claims, ","))
+}
+
+func TestWikidata(t *testing.T) {
+	items := map[string]string{
+		"Q1": wdItem("Q1", "Acme Widgets", "https://acme.example/ normal", "https://www.acmewidgets.com/ preferred", "http://old-acme.com deprecated"),
+		"Q2": wdItem("Q2", "Acme Widgets Ltd", "https://acme-widgets.co.uk normal"),
+		"Q3": wdItem("Q3", "Acme Widget Co", "https://acmewidgetco.com normal"),
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !strings.HasPrefix(r.UserAgent(), "rank/") {
+			t.Errorf("user agent %q", r.UserAgent())
+		}
+		switch {
+		case q.Get("action") == "wbgetentities" && q.Get("titles") == "Acme_Widgets":
+			fmt.Fprint(w, `{"entities":{"Q1":{"id":"Q1"}}}`)
+		case q.Get("action") == "wbgetentities":
+			var out []string
+			for _, id := range strings.Split(q.Get("ids"), "|") {
+				out = append(out, items[id])
+			}
+			fmt.Fprintf(w, `{"entities":{%s}}`, strings.Join(out, ","))
+		case q.Get("srsearch") == "haswbstatement:P2622=01234567":
+			fmt.Fprint(w, `{"query":{"search":[{"title":"Q2"}]}}`)
+		case q.Get("srsearch") == "haswbstatement:P1278=213800ABCDEF12345678":
+			fmt.Fprint(w, `{"query":{"search":[{"title":"Q1"}]}}`)
+		case q.Get("srsearch") == "Acme Widgets haswbstatement:P856 haswbstatement:P17=Q145":
+			fmt.Fprint(w, `{"query":{"search":[{"title":"Q3"},{"title":"Q2"}]}}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.RawQuery)
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	defer s.Close()
+
+	c := &util.Company{Name: "Acme Widgets Ltd", CompanyNumber: "1234567", JurisdictionCode: "gb", WikipediaID: "Acme_Widgets", LEI: "213800abcdef12345678"}
+	w := Wikidata{Company: c, Jurisdiction: LookupJurisdiction("gb"), BaseURL: s.URL}
+	cands, err := w.Lookup(context.Background(), "Acme Widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		domain string
+		score  float64
+	}{{"acmewidgets.com", 1}, {"acme.example", 1}, {"acme-widgets.co.uk", 1}, {"acmewidgetco.com", 0.5}}
+	if len(cands) != len(want) {
+		t.Fatalf("got %d candidates, want %d", len(cands), len(want))
+	}
+	for i, wc := range want {
+		if c := cands[i]; c.Domain != wc.domain || c.Hits[0].Score != wc.score {
+			t.Errorf("candidate %d is %s scoring %v, want %s scoring %v", i, c.Domain, c.Hits[0].Score, wc.domain, wc.score)
+		}
+	}
+}
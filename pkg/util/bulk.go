@@ -0,0 +1,279 @@
+package util
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BulkIndex resolves companies from the Companies House "BasicCompanyData" csv snapshot
+// (http://download.companieshouse.gov.uk/en_output.html) without touching the network.
+type BulkIndex struct {
+	byNumber map[string]*bulkRecord
+	byName   map[string][]string
+	loaded   time.Time
+}
+
+type bulkRecord struct {
+	Name          string
+	Number        string
+	Street        []string
+	PostTown      string
+	County        string
+	Country       string
+	PostCode      string
+	Category      string
+	Status        string
+	Dissolution   string
+	Incorporation string
+	SIC           []string
+	PreviousNames [][2]string // date, name
+	URI           string
+}
+
+var (
+	nameStrip = regexp.MustCompile(`[^a-z0-9]+`)
+	nameStop  = regexp.MustCompile(`\b(limited|ltd|plc|llp|lp|the|company|co)\b`)
+)
+
+// NormaliseCompanyName is used to key companies by name, "The Acme Co. Ltd" and "ACME LIMITED" end up the same.
+func NormaliseCompanyName(name string) string {
+	n := strings.ReplaceAll(strings.ToLower(name), "&", " and ")
+	n = nameStrip.ReplaceAllString(n, " ")
+	n = nameStop.ReplaceAllString(n, " ")
+	return strings.Join(strings.Fields(n), " ")
+}
+
+func NewBulkIndex() *BulkIndex {
+	return &BulkIndex{
+		byNumber: map[string]*bulkRecord{},
+		byName:   map[string][]string{},
+	}
+}
+
+// LoadBulkIndex reads every csv (or zip of csvs) matched by patterns, the snapshot is usually split into
+// several BasicCompanyData-YYYY-MM-DD-partN_M.zip files so globs are accepted.
+func LoadBulkIndex(patterns ...string) (*BulkIndex, error) {
+	idx := NewBulkIndex()
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no bulk data files match '%s'", pattern)
+		}
+		for _, m := range matches {
+			if err := idx.LoadFile(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	idx.loaded = time.Now()
+	return idx, nil
+}
+
+func (b *BulkIndex) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.ToLower(filepath.Ext(path)) == ".zip" {
+		st, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return b.LoadZip(f, st.Size())
+	}
+	n, err := b.Load(f)
+	logrus.WithField("file", path).WithField("companies", n).Debug("loaded bulk data")
+	return err
+}
+
+// LoadZip loads every csv in a zip, which is how Companies House hands the snapshot out.
+func (b *BulkIndex) LoadZip(r io.ReaderAt, size int64) error {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range z.File {
+		if strings.ToLower(filepath.Ext(zf.Name)) != ".csv" {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		n, err := b.Load(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", zf.Name, err)
+		}
+		logrus.WithField("member", zf.Name).WithField("companies", n).Debug("loaded bulk data")
+	}
+	return nil
+}
+
+// Load adds every row of a BasicCompanyData csv to the index, returning how many were read.
+func (b *BulkIndex) Load(r io.Reader) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return 0, err
+	}
+	// some of the column names come with a leading space.
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))] = i
+	}
+	if _, ok := cols["CompanyNumber"]; !ok {
+		return 0, fmt.Errorf("not a BasicCompanyData csv, no CompanyNumber column")
+	}
+	n := 0
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		rec := &bulkRecord{
+			Name:          get("CompanyName"),
+			Number:        get("CompanyNumber"),
+			PostTown:      get("RegAddress.PostTown"),
+			County:        get("RegAddress.County"),
+			Country:       get("RegAddress.Country"),
+			PostCode:      get("RegAddress.PostCode"),
+			Category:      get("CompanyCategory"),
+			Status:        get("CompanyStatus"),
+			Dissolution:   isoDate(get("DissolutionDate")),
+			Incorporation: isoDate(get("IncorporationDate")),
+			URI:           get("URI"),
+		}
+		for _, col := range []string{"RegAddress.CareOf", "RegAddress.POBox", "RegAddress.AddressLine1", "RegAddress.AddressLine2"} {
+			if v := get(col); v != "" {
+				rec.Street = append(rec.Street, v)
+			}
+		}
+		for i := 1; i <= 4; i++ {
+			if v := get(fmt.Sprintf("SICCode.SicText_%d", i)); v != "" && v != "None Supplied" {
+				rec.SIC = append(rec.SIC, v)
+			}
+		}
+		for i := 1; i <= 10; i++ {
+			if v := get(fmt.Sprintf("PreviousName_%d.CompanyName", i)); v != "" {
+				rec.PreviousNames = append(rec.PreviousNames, [2]string{isoDate(get(fmt.Sprintf("PreviousName_%d.CONDATE", i))), v})
+			}
+		}
+		if rec.Number == "" {
+			continue
+		}
+		b.byNumber[rec.Number] = rec
+		key := NormaliseCompanyName(rec.Name)
+		b.byName[key] = append(b.byName[key], rec.Number)
+		n++
+	}
+}
+
+func (b *BulkIndex) Name() string {
+	return "BulkCompanyData"
+}
+
+func (b *BulkIndex) Len() int {
+	return len(b.byNumber)
+}
+
+func (b *BulkIndex) Lookup(ctx context.Context, jurisdiction, number string) (*Company, error) {
+	if jurisdiction != "" && strings.ToLower(jurisdiction) != "gb" {
+		return nil, ErrUnsupportedJurisdiction
+	}
+	rec, ok := b.byNumber[strings.ToUpper(strings.TrimSpace(number))]
+	if !ok {
+		return nil, ErrCompanyNotFound
+	}
+	return b.toCompany(rec), nil
+}
+
+// FindByName returns every company whose normalised name matches name.
+func (b *BulkIndex) FindByName(name string) []*Company {
+	var out []*Company
+	for _, number := range b.byName[NormaliseCompanyName(name)] {
+		out = append(out, b.toCompany(b.byNumber[number]))
+	}
+	return out
+}
+
+func (b *BulkIndex) toCompany(rec *bulkRecord) *Company {
+	c := &Company{
+		Name:              rec.Name,
+		CompanyNumber:     rec.Number,
+		JurisdictionCode:  "gb",
+		IncorporationDate: rec.Incorporation,
+		CompanyType:       rec.Category,
+		CurrentStatus:     rec.Status,
+		Inactive:          !strings.HasPrefix(strings.ToLower(rec.Status), "active"),
+		RegistryURL:       rec.URI,
+		RetrievedAt:       b.loaded,
+	}
+	if rec.Dissolution != "" {
+		c.DissolutionDate = rec.Dissolution
+	}
+	c.Source.Publisher = "UK Companies House"
+	c.Source.URL = "http://download.companieshouse.gov.uk/en_output.html"
+	c.Source.RetrievedAt = b.loaded
+	c.RegisteredAddress = Address{
+		StreetAddress: strings.Join(rec.Street, ", "),
+		Locality:      rec.PostTown,
+		Region:        rec.County,
+		PostalCode:    rec.PostCode,
+		Country:       rec.Country,
+	}
+	c.RegisteredAddressInFull = joinNonEmpty(append(append([]string{}, rec.Street...), rec.PostTown, rec.County, rec.PostCode, rec.Country), ", ")
+	for _, sic := range rec.SIC {
+		// "62020 - Information technology consultancy activities"
+		var entry IndustryCodeEntry
+		parts := strings.SplitN(sic, " - ", 2)
+		entry.IndustryCode.Code = strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			entry.IndustryCode.Description = strings.TrimSpace(parts[1])
+		}
+		entry.IndustryCode.CodeSchemeID = "uk_sic_2007"
+		entry.IndustryCode.CodeSchemeName = "UK SIC Classification 2007"
+		entry.IndustryCode.UID = "uk_sic_2007-" + entry.IndustryCode.Code
+		c.IndustryCodes = append(c.IndustryCodes, entry)
+	}
+	for _, pn := range rec.PreviousNames {
+		c.PreviousNames = append(c.PreviousNames, map[string]interface{}{
+			"company_name": pn[1],
+			"con_date":     pn[0],
+		})
+	}
+	c.BuildBag()
+	return c
+}
+
+// isoDate turns the snapshot's dd/mm/yyyy into the yyyy-mm-dd everything else uses.
+func isoDate(d string) string {
+	if t, err := time.Parse("02/01/2006", d); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return d
+}
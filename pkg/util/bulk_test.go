@@ -0,0 +1,108 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+)
+
+// a cut down BasicCompanyData csv, the leading spaces and byte order mark are Companies House's.
+const bulkCSV = "\ufeffCompanyName, CompanyNumber,RegAddress.CareOf,RegAddress.POBox,RegAddress.AddressLine1, RegAddress.AddressLine2," +
+	"RegAddress.PostTown,RegAddress.County,RegAddress.Country,RegAddress.PostCode,CompanyCategory,CompanyStatus," +
+	"DissolutionDate,IncorporationDate,SICCode.SicText_1,SICCode.SicText_2,URI,PreviousName_1.CONDATE, PreviousName_1.CompanyName\n" +
+	`"THE ACME WIDGETS CO. LTD",01234567,,,"1 FORGE LANE",,SHEFFIELD,SOUTH YORKSHIRE,ENGLAND,S1 2AB,Private Limited Company,Active,,01/03/1999,"25990 - Manufacture of other fabricated metal products n.e.c.",None Supplied,http://business.data.gov.uk/id/company/01234567,14/06/2005,ACME GADGETS LIMITED` + "\n" +
+	`ACME WIDGETS LIMITED,SC123456,,,"2 MILL ROAD",,GLASGOW,,SCOTLAND,G1 1AA,Private Limited Company,Dissolved,02/02/2020,05/05/2010,,,,,` + "\n" +
+	// cut short after the number, which is still enough to find it by.
+	`WIDGET WORLD LTD,09876543,,,"3 HIGH STREET"` + "\n" +
+	// no number, nothing to key it on.
+	`NAMELESS LTD,,,,,,,,,,,,,,,,,,` + "\n"
+
+func bulkZip(t *testing.T) *bytes.Reader {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, body := range map[string]string{"BasicCompanyData-2024-10-01-part1_1.csv": bulkCSV, "README.txt": "not a csv"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b.Bytes())
+}
+
+func TestBulkIndex(t *testing.T) {
+	idx := NewBulkIndex()
+	z := bulkZip(t)
+	if err := idx.LoadZip(z, z.Size()); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 3 {
+		t.Errorf("loaded %d companies, want 3", idx.Len())
+	}
+
+	c, err := idx.Lookup(context.Background(), "gb", " 01234567 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "THE ACME WIDGETS CO. LTD" || c.IncorporationDate != "1999-03-01" || c.Inactive ||
+		c.RegisteredAddressInFull != "1 FORGE LANE, SHEFFIELD, SOUTH YORKSHIRE, S1 2AB, ENGLAND" {
+		t.Errorf("got %+v", c)
+	}
+	if len(c.IndustryCodes) != 1 || c.IndustryCodes[0].IndustryCode.Code != "25990" {
+		t.Errorf("got industry codes %+v", c.IndustryCodes)
+	}
+	if pn := c.PreviousNameList(); len(pn) != 1 || pn[0] != "ACME GADGETS LIMITED" {
+		t.Errorf("got previous names %v", pn)
+	}
+
+	if c, err := idx.Lookup(context.Background(), "", "sc123456"); err != nil || !c.Inactive || c.DissolutionDate != "2020-02-02" {
+		t.Errorf("got %+v, %v for the dissolved company", c, err)
+	}
+	if c, err := idx.Lookup(context.Background(), "gb", "09876543"); err != nil || c.Name != "WIDGET WORLD LTD" || c.RegisteredAddress.StreetAddress != "3 HIGH STREET" {
+		t.Errorf("got %+v, %v for the short row", c, err)
+	}
+	if _, err := idx.Lookup(context.Background(), "gb", "00000000"); err != ErrCompanyNotFound {
+		t.Errorf("got %v for a missing number", err)
+	}
+	if _, err := idx.Lookup(context.Background(), "ie", "01234567"); err != ErrUnsupportedJurisdiction {
+		t.Errorf("got %v for another jurisdiction", err)
+	}
+
+	// both acmes normalise to "acme widgets", in the order they were read.
+	var numbers []string
+	for _, c := range idx.FindByName("Acme Widgets Limited") {
+		numbers = append(numbers, c.CompanyNumber)
+	}
+	if len(numbers) != 2 || numbers[0] != "01234567" || numbers[1] != "SC123456" {
+		t.Errorf("found %v by name, want both acmes", numbers)
+	}
+	if found := idx.FindByName("the widget world company"); len(found) != 1 {
+		t.Errorf("found %d widget worlds", len(found))
+	}
+	if found := idx.FindByName("nameless"); len(found) != 0 {
+		t.Errorf("found %d companies without a number", len(found))
+	}
+}
+
+func TestNormaliseCompanyName(t *testing.T) {
+	for name, want := range map[string]string{
+		"The Acme Co. Ltd":        "acme",
+		"ACME LIMITED":            "acme",
+		"Smith & Jones PLC":       "smith and jones",
+		"  Widgets-R-Us (UK) LLP": "widgets r us uk",
+	} {
+		if got := NormaliseCompanyName(name); got != want {
+			t.Errorf("NormaliseCompanyName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBulkIndexNotBulkData(t *testing.T) {
+	if _, err := NewBulkIndex().Load(bytes.NewReader([]byte("name,number\nacme,1\n"))); err == nil {
+		t.Error("expected an error for a csv without CompanyNumber")
+	}
+}
@@ -0,0 +1,256 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CompaniesHouseURL = "https://api.company-information.service.gov.uk"
+
+// CompaniesHouse talks to the UK Companies House public data API. Only gb numbers can be looked up.
+type CompaniesHouse struct {
+	APIKey     string
+	BaseURL    string
+	Client     *http.Client
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+func NewCompaniesHouse(key string) *CompaniesHouse {
+	return &CompaniesHouse{
+		APIKey:     key,
+		BaseURL:    CompaniesHouseURL,
+		Client:     HTTPClient(),
+		MaxRetries: 5,
+		Backoff:    2 * time.Second,
+	}
+}
+
+func (ch *CompaniesHouse) Name() string {
+	return "CompaniesHouse"
+}
+
+type chAddress struct {
+	Premises     string `json:"premises"`
+	AddressLine1 string `json:"address_line_1"`
+	AddressLine2 string `json:"address_line_2"`
+	Locality     string `json:"locality"`
+	Region       string `json:"region"`
+	PostalCode   string `json:"postal_code"`
+	Country      string `json:"country"`
+}
+
+type chProfile struct {
+	CompanyName             string    `json:"company_name"`
+	CompanyNumber           string    `json:"company_number"`
+	CompanyStatus           string    `json:"company_status"`
+	Type                    string    `json:"type"`
+	DateOfCreation          string    `json:"date_of_creation"`
+	DateOfCessation         string    `json:"date_of_cessation"`
+	RegisteredOfficeAddress chAddress `json:"registered_office_address"`
+	SicCodes                []string  `json:"sic_codes"`
+	PreviousCompanyNames    []struct {
+		Name          string `json:"name"`
+		EffectiveFrom string `json:"effective_from"`
+		CeasedOn      string `json:"ceased_on"`
+	} `json:"previous_company_names"`
+}
+
+type chOfficers struct {
+	Items []struct {
+		Name        string `json:"name"`
+		OfficerRole string `json:"officer_role"`
+		AppointedOn string `json:"appointed_on"`
+		ResignedOn  string `json:"resigned_on"`
+		Occupation  string `json:"occupation"`
+	} `json:"items"`
+}
+
+type chFilings struct {
+	Items []struct {
+		TransactionID string `json:"transaction_id"`
+		Category      string `json:"category"`
+		Date          string `json:"date"`
+		Description   string `json:"description"`
+		Type          string `json:"type"`
+	} `json:"items"`
+}
+
+func (ch *CompaniesHouse) Lookup(ctx context.Context, jurisdiction, number string) (*Company, error) {
+	if jurisdiction != "" && strings.ToLower(jurisdiction) != "gb" {
+		return nil, ErrUnsupportedJurisdiction
+	}
+	number = strings.ToUpper(strings.TrimSpace(number))
+	var profile chProfile
+	if err := ch.get(ctx, "/company/"+number, &profile); err != nil {
+		return nil, err
+	}
+	company := ch.toCompany(&profile)
+
+	// officers and filings are nice to have, a missing list shouldn't lose us the company.
+	var officers chOfficers
+	if err := ch.get(ctx, "/company/"+number+"/officers", &officers); err != nil && err != ErrCompanyNotFound {
+		logrus.WithError(err).WithField("company_number", number).Warn("error getting officers from companies house")
+	}
+	for _, o := range officers.Items {
+		var entry OfficerEntry
+		entry.Officer.Name = o.Name
+		entry.Officer.Position = o.OfficerRole
+		entry.Officer.StartDate = o.AppointedOn
+		entry.Officer.Occupation = o.Occupation
+		if o.ResignedOn != "" {
+			entry.Officer.EndDate = o.ResignedOn
+			entry.Officer.Inactive = true
+		}
+		company.Officers = append(company.Officers, entry)
+	}
+
+	var filings chFilings
+	if err := ch.get(ctx, "/company/"+number+"/filing-history", &filings); err != nil && err != ErrCompanyNotFound {
+		logrus.WithError(err).WithField("company_number", number).Warn("error getting filing history from companies house")
+	}
+	for _, f := range filings.Items {
+		var entry FilingEntry
+		entry.Filing.UID = f.TransactionID
+		entry.Filing.Title = f.Category
+		entry.Filing.Description = f.Description
+		entry.Filing.FilingTypeCode = f.Type
+		entry.Filing.Date = f.Date
+		company.Filings = append(company.Filings, entry)
+	}
+
+	company.BuildBag()
+	return company, nil
+}
+
+func (ch *CompaniesHouse) toCompany(p *chProfile) *Company {
+	c := &Company{
+		Name:              p.CompanyName,
+		CompanyNumber:     p.CompanyNumber,
+		JurisdictionCode:  "gb",
+		IncorporationDate: p.DateOfCreation,
+		CompanyType:       p.Type,
+		CurrentStatus:     p.CompanyStatus,
+		Inactive:          p.CompanyStatus != "active",
+		RegistryURL:       "https://find-and-update.company-information.service.gov.uk/company/" + p.CompanyNumber,
+		RetrievedAt:       time.Now(),
+	}
+	if p.DateOfCessation != "" {
+		c.DissolutionDate = p.DateOfCessation
+	}
+	c.Source.Publisher = "UK Companies House"
+	c.Source.URL = strings.TrimRight(ch.BaseURL, "/") + "/company/" + p.CompanyNumber
+	c.Source.RetrievedAt = c.RetrievedAt
+
+	a := p.RegisteredOfficeAddress
+	street := []string{}
+	for _, s := range []string{strings.TrimSpace(a.Premises + " " + a.AddressLine1), a.AddressLine2} {
+		if s != "" {
+			street = append(street, s)
+		}
+	}
+	c.RegisteredAddress = Address{
+		StreetAddress: strings.Join(street, ", "),
+		Locality:      a.Locality,
+		Region:        a.Region,
+		PostalCode:    a.PostalCode,
+		Country:       a.Country,
+	}
+	full := append(street, a.Locality, a.Region, a.PostalCode, a.Country)
+	c.RegisteredAddressInFull = joinNonEmpty(full, ", ")
+
+	for _, sic := range p.SicCodes {
+		var entry IndustryCodeEntry
+		entry.IndustryCode.Code = sic
+		entry.IndustryCode.CodeSchemeID = "uk_sic_2007"
+		entry.IndustryCode.CodeSchemeName = "UK SIC Classification 2007"
+		entry.IndustryCode.UID = "uk_sic_2007-" + sic
+		c.IndustryCodes = append(c.IndustryCodes, entry)
+	}
+	for _, pn := range p.PreviousCompanyNames {
+		// same shape opencorporates gives us.
+		c.PreviousNames = append(c.PreviousNames, map[string]interface{}{
+			"company_name": pn.Name,
+			"start_date":   pn.EffectiveFrom,
+			"con_date":     pn.CeasedOn,
+		})
+	}
+	return c
+}
+
+// get fetches path into v, backing off and retrying when we're rate limited.
+func (ch *CompaniesHouse) get(ctx context.Context, path string, v interface{}) error {
+	client := ch.Client
+	if client == nil {
+		client = HTTPClient()
+	}
+	uri := strings.TrimRight(ch.BaseURL, "/") + path
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(ch.APIKey, "")
+		req.Header.Set("Accept", "application/json")
+		r, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		switch r.StatusCode {
+		case http.StatusOK:
+			defer r.Body.Close()
+			return json.NewDecoder(r.Body).Decode(v)
+		case http.StatusNotFound:
+			r.Body.Close()
+			return ErrCompanyNotFound
+		case http.StatusTooManyRequests:
+			r.Body.Close()
+			if attempt >= ch.MaxRetries {
+				return fmt.Errorf("companies house rate limit: gave up after %d attempts", attempt+1)
+			}
+			wait := retryAfter(r.Header.Get("Retry-After"), ch.Backoff<<uint(attempt))
+			logrus.WithField("url", uri).WithField("wait", wait).Debug("companies house rate limited, backing off")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		default:
+			r.Body.Close()
+			return fmt.Errorf("companies house returned %s for %s", r.Status, path)
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header (seconds or http date), falling back to def.
+func retryAfter(h string, def time.Duration) time.Duration {
+	if h == "" {
+		return def
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return def
+}
+
+func joinNonEmpty(parts []string, sep string) string {
+	out := []string{}
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, sep)
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chProfileJSON = `{"company_name":"ACME WIDGETS LIMITED","company_number":"01234567","company_status":"active",
+	"type":"ltd","date_of_creation":"1999-01-02","registered_office_address":{"premises":"1","address_line_1":"High Street",
+	"locality":"London","postal_code":"EC1A 1AA","country":"England"},"sic_codes":["62020"],
+	"previous_company_names":[{"name":"ACME GADGETS LIMITED","effective_from":"1999-01-02","ceased_on":"2005-06-07"}]}`
+
+const chOfficersJSON = `{"items":[{"name":"SMITH, Jane","officer_role":"director","appointed_on":"2001-01-01","occupation":"Engineer"},
+	{"name":"JONES, Bob","officer_role":"secretary","appointed_on":"1999-01-02","resigned_on":"2003-04-05"}]}`
+
+func TestCompaniesHouseLookup(t *testing.T) {
+	var (
+		limited = map[string]int{}
+		seen    []string
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "key" {
+			t.Errorf("%s without the api key", r.URL.Path)
+		}
+		seen = append(seen, r.URL.Path)
+		// the profile is rate limited twice, saying to come back straight away in seconds and as a date.
+		if r.URL.Path == "/company/01234567" && limited[r.URL.Path] < 2 {
+			w.Header().Set("Retry-After", []string{"0", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)}[limited[r.URL.Path]])
+			limited[r.URL.Path]++
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		switch r.URL.Path {
+		case "/company/01234567":
+			w.Write([]byte(chProfileJSON))
+		case "/company/01234567/officers":
+			w.Write([]byte(chOfficersJSON))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	// backing off by the hour rather than Retry-After would blow the deadline.
+	ch := &CompaniesHouse{APIKey: "key", BaseURL: s.URL, Client: s.Client(), MaxRetries: 5, Backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := ch.Lookup(ctx, "gb", " 01234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(seen, " "); got != "/company/01234567 /company/01234567 /company/01234567 /company/01234567/officers /company/01234567/filing-history" {
+		t.Errorf("requests were %s", got)
+	}
+	if c.Name != "ACME WIDGETS LIMITED" || c.RegisteredAddressInFull != "1 High Street, London, EC1A 1AA, England" || c.Inactive {
+		t.Errorf("got company %s at %s", c.Name, c.RegisteredAddressInFull)
+	}
+	if pn := c.PreviousNameList(); len(pn) != 1 || pn[0] != "ACME GADGETS LIMITED" {
+		t.Errorf("previous names %v", pn)
+	}
+	if len(c.PreviousNames) == 1 {
+		if m, _ := c.PreviousNames[0].(map[string]interface{}); m["start_date"] != "1999-01-02" || m["con_date"] != "2005-06-07" {
+			t.Errorf("previous name dates %v", m)
+		}
+	}
+	if len(c.Officers) != 2 {
+		t.Fatalf("got %d officers, want 2", len(c.Officers))
+	}
+	if o := c.Officers[0].Officer; o.Name != "SMITH, Jane" || o.Position != "director" || o.Occupation != "Engineer" || o.Inactive {
+		t.Errorf("first officer %+v", o)
+	}
+	if o := c.Officers[1].Officer; o.EndDate != "2003-04-05" || !o.Inactive {
+		t.Errorf("resigned officer %+v", o)
+	}
+	if !strings.Contains(c.Bag, "SMITH") {
+		t.Errorf("officers aren't in the bag: %s", c.Bag)
+	}
+}
+
+func TestCompaniesHouseGivesUp(t *testing.T) {
+	n := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer s.Close()
+	ch := &CompaniesHouse{BaseURL: s.URL, Client: s.Client(), MaxRetries: 2, Backoff: time.Millisecond}
+	if _, err := ch.Lookup(context.Background(), "gb", "01234567"); err == nil || n != 3 {
+		t.Errorf("got %v after %d requests, want to give up after 3", err, n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for h, want := range map[string]time.Duration{
+		"":                              time.Minute,
+		"3":                             3 * time.Second,
+		"soon":                          time.Minute,
+		"Mon, 02 Jan 2006 15:04:05 GMT": 0,
+	} {
+		if got := retryAfter(h, time.Minute); got != want {
+			t.Errorf("retryAfter(%q) = %s, want %s", h, got, want)
+		}
+	}
+}
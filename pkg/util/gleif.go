@@ -0,0 +1,344 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const GLEIFURL = "https://api.gleif.org/api/v1"
+
+// GLEIF talks to the GLEIF LEI api, linking a registry number to its Legal Entity Identifier, the names the entity
+// has gone by and the group it sits in. It's also a CompanyProvider, thin as its companies are.
+type GLEIF struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewGLEIF() *GLEIF {
+	return &GLEIF{BaseURL: GLEIFURL, Client: HTTPClient()}
+}
+
+func (g *GLEIF) Name() string {
+	return "GLEIF"
+}
+
+// LEIRecord is the part of a GLEIF level 1 record we care about.
+type LEIRecord struct {
+	LEI          string
+	LegalName    string
+	OtherNames   []LEIName
+	Jurisdiction string // ISO 3166, "GB", "US-DE"
+	RegisteredAs string // the number the registration authority knows it by
+	Status       string
+	Address      Address
+	Registered   string // when the LEI was first issued
+	// level 1 data has no website field, these are any urls the record happens to carry anyway.
+	Websites []string
+}
+
+// LEIName is one of the entity's other names, Type is GLEIF's (PREVIOUS_LEGAL_NAME, TRADING_OR_OPERATING_NAME...).
+type LEIName struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// LEIRelationship is another entity in the same group, from GLEIF's level 2 data.
+type LEIRelationship struct {
+	LEI          string   `json:"lei"`
+	Name         string   `json:"name"`
+	Relationship string   `json:"relationship"` // direct_parent, ultimate_parent or child
+	Websites     []string `json:"websites,omitempty"`
+}
+
+type gleifAddress struct {
+	AddressLines []string `json:"addressLines"`
+	City         string   `json:"city"`
+	Region       string   `json:"region"`
+	Country      string   `json:"country"`
+	PostalCode   string   `json:"postalCode"`
+}
+
+type gleifAttributes struct {
+	LEI    string `json:"lei"`
+	Entity struct {
+		LegalName                LEIName      `json:"legalName"`
+		OtherNames               []LEIName    `json:"otherNames"`
+		TransliteratedOtherNames []LEIName    `json:"transliteratedOtherNames"`
+		LegalAddress             gleifAddress `json:"legalAddress"`
+		Jurisdiction             string       `json:"jurisdiction"`
+		RegisteredAs             string       `json:"registeredAs"`
+		Status                   string       `json:"status"`
+	} `json:"entity"`
+	Registration struct {
+		InitialRegistrationDate string `json:"initialRegistrationDate"`
+	} `json:"registration"`
+}
+
+type gleifRecord struct {
+	ID         string          `json:"id"`
+	Attributes json.RawMessage `json:"attributes"`
+}
+
+// LookupLEI fetches a record by its LEI.
+func (g *GLEIF) LookupLEI(ctx context.Context, lei string) (*LEIRecord, error) {
+	var res struct {
+		Data gleifRecord `json:"data"`
+	}
+	if err := g.get(ctx, "/lei-records/"+url.PathEscape(strings.ToUpper(strings.TrimSpace(lei))), &res); err != nil {
+		return nil, err
+	}
+	return res.Data.record()
+}
+
+// FindLEI finds the record registered as number in the jurisdiction (opencorporates style, "gb", "us_de").
+func (g *GLEIF) FindLEI(ctx context.Context, jurisdiction, number string) (*LEIRecord, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return nil, ErrCompanyNotFound
+	}
+	var res struct {
+		Data []gleifRecord `json:"data"`
+	}
+	q := url.Values{"filter[entity.registeredAs]": {number}, "page[size]": {"50"}}
+	if err := g.get(ctx, "/lei-records?"+q.Encode(), &res); err != nil {
+		return nil, err
+	}
+	// registeredAs isn't unique across the world, the jurisdiction settles it. GLEIF's can be more specific than
+	// ours (GB-ENG), or less (US for us_de).
+	want := gleifJurisdiction(jurisdiction)
+	for _, d := range res.Data {
+		r, err := d.record()
+		if err != nil {
+			return nil, err
+		}
+		got := strings.ToUpper(r.Jurisdiction)
+		if want == "" || strings.HasPrefix(got, want) || (got != "" && strings.HasPrefix(want, got)) {
+			return r, nil
+		}
+	}
+	return nil, ErrCompanyNotFound
+}
+
+// Relationships are the entity's direct and ultimate parents and direct children, when it reports them.
+func (g *GLEIF) Relationships(ctx context.Context, lei string) ([]LEIRelationship, error) {
+	base := "/lei-records/" + url.PathEscape(lei)
+	var out []LEIRelationship
+	for _, rel := range []string{"direct-parent", "ultimate-parent"} {
+		var res struct {
+			Data gleifRecord `json:"data"`
+		}
+		if err := g.get(ctx, base+"/"+rel, &res); err == ErrCompanyNotFound {
+			continue
+		} else if err != nil {
+			return out, err
+		}
+		if r, err := res.Data.record(); err == nil && r.LEI != "" {
+			out = append(out, LEIRelationship{LEI: r.LEI, Name: r.LegalName, Relationship: strings.Replace(rel, "-", "_", 1), Websites: r.Websites})
+		}
+	}
+	var children struct {
+		Data []gleifRecord `json:"data"`
+	}
+	if err := g.get(ctx, base+"/direct-children?page%5Bsize%5D=50", &children); err != nil && err != ErrCompanyNotFound {
+		return out, err
+	}
+	for _, d := range children.Data {
+		if r, err := d.record(); err == nil && r.LEI != "" {
+			out = append(out, LEIRelationship{LEI: r.LEI, Name: r.LegalName, Relationship: "child", Websites: r.Websites})
+		}
+	}
+	return out, nil
+}
+
+// Lookup makes GLEIF a CompanyProvider, for companies we only have an LEI record for.
+func (g *GLEIF) Lookup(ctx context.Context, jurisdiction, number string) (*Company, error) {
+	r, err := g.FindLEI(ctx, jurisdiction, number)
+	if err != nil {
+		return nil, err
+	}
+	c := &Company{
+		Name:              r.LegalName,
+		CompanyNumber:     r.RegisteredAs,
+		JurisdictionCode:  strings.Replace(strings.ToLower(r.Jurisdiction), "-", "_", 1),
+		CurrentStatus:     r.Status,
+		Inactive:          r.Status != "" && r.Status != "ACTIVE",
+		RegisteredAddress: r.Address,
+		RetrievedAt:       time.Now(),
+	}
+	c.RegisteredAddressInFull = joinNonEmpty([]string{r.Address.StreetAddress, r.Address.Locality, r.Address.Region, r.Address.PostalCode, r.Address.Country}, ", ")
+	c.Source.Publisher = "GLEIF"
+	c.Source.URL = strings.TrimRight(g.BaseURL, "/") + "/lei-records/" + r.LEI
+	c.Source.RetrievedAt = c.RetrievedAt
+	c.ApplyLEI(r)
+	c.BuildBag()
+	return c, nil
+}
+
+// Enrich finds the company's LEI (one it already has, an opencorporates identifier, or its registry number) and
+// pulls the record and its relationships into it. A company without an LEI is left alone and ErrCompanyNotFound
+// returned.
+func (g *GLEIF) Enrich(ctx context.Context, c *Company) error {
+	var (
+		r   *LEIRecord
+		err error
+	)
+	if lei := c.LEICode(); lei != "" {
+		r, err = g.LookupLEI(ctx, lei)
+	} else {
+		r, err = g.FindLEI(ctx, c.JurisdictionCode, c.CompanyNumber)
+	}
+	if err != nil {
+		return err
+	}
+	c.ApplyLEI(r)
+	rels, err := g.Relationships(ctx, r.LEI)
+	c.LEIRelationships = rels
+	// the bag came from the provider's own json, add the names rather than rebuilding it.
+	names := []string{r.LegalName}
+	for _, n := range r.OtherNames {
+		names = AppendUniq(names, n.Name)
+	}
+	c.Bag = strings.TrimSpace(c.Bag + " " + strings.Join(names, " "))
+	return err
+}
+
+// ApplyLEI copies what an LEI record tells us that the company doesn't already know.
+func (c *Company) ApplyLEI(r *LEIRecord) {
+	c.LEI = r.LEI
+	known := map[string]bool{strings.ToLower(c.Name): true}
+	for _, n := range c.PreviousNameList() {
+		known[strings.ToLower(n)] = true
+	}
+	for _, n := range c.AlternativeNames {
+		if m, ok := n.(map[string]interface{}); ok {
+			if name, ok := m["company_name"].(string); ok {
+				known[strings.ToLower(name)] = true
+			}
+		}
+	}
+	if c.Name == "" {
+		c.Name = r.LegalName
+	} else if !known[strings.ToLower(r.LegalName)] {
+		known[strings.ToLower(r.LegalName)] = true
+		c.AlternativeNames = append(c.AlternativeNames, map[string]interface{}{"company_name": r.LegalName, "type": "legal"})
+	}
+	for _, n := range r.OtherNames {
+		if n.Name == "" || known[strings.ToLower(n.Name)] {
+			continue
+		}
+		known[strings.ToLower(n.Name)] = true
+		// same shapes opencorporates gives us.
+		if n.Type == "PREVIOUS_LEGAL_NAME" {
+			c.PreviousNames = append(c.PreviousNames, map[string]interface{}{"company_name": n.Name})
+		} else {
+			c.AlternativeNames = append(c.AlternativeNames, map[string]interface{}{"company_name": n.Name, "type": strings.ToLower(n.Type)})
+		}
+	}
+	for _, w := range r.Websites {
+		c.Websites = AppendUniq(c.Websites, w)
+	}
+}
+
+// LEICode is the company's LEI, from GLEIF or an opencorporates identifier.
+func (c *Company) LEICode() string {
+	if c.LEI != "" {
+		return c.LEI
+	}
+	return c.Identifier("lei")
+}
+
+func (d gleifRecord) record() (*LEIRecord, error) {
+	var a gleifAttributes
+	if err := json.Unmarshal(d.Attributes, &a); err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	json.Unmarshal(d.Attributes, &raw)
+	e := a.Entity
+	r := &LEIRecord{
+		LEI:          a.LEI,
+		LegalName:    e.LegalName.Name,
+		Jurisdiction: e.Jurisdiction,
+		RegisteredAs: e.RegisteredAs,
+		Status:       e.Status,
+		Registered:   a.Registration.InitialRegistrationDate,
+		Websites:     findURLs(raw, nil),
+	}
+	if r.LEI == "" {
+		r.LEI = d.ID
+	}
+	if len(r.Registered) > 10 {
+		r.Registered = r.Registered[:10]
+	}
+	r.OtherNames = append(append(r.OtherNames, e.OtherNames...), e.TransliteratedOtherNames...)
+	r.Address = Address{
+		StreetAddress: joinNonEmpty(e.LegalAddress.AddressLines, ", "),
+		Locality:      e.LegalAddress.City,
+		Region:        e.LegalAddress.Region,
+		PostalCode:    e.LegalAddress.PostalCode,
+		Country:       e.LegalAddress.Country,
+	}
+	return r, nil
+}
+
+// findURLs walks decoded json for anything that looks like a website.
+func findURLs(v interface{}, out []string) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, e := range v {
+			out = findURLs(e, out)
+		}
+	case []interface{}:
+		for _, e := range v {
+			out = findURLs(e, out)
+		}
+	case string:
+		s := strings.TrimSpace(v)
+		l := strings.ToLower(s)
+		if strings.HasPrefix(l, "www.") {
+			s, l = "http://"+s, "http://"+l
+		}
+		if (strings.HasPrefix(l, "http://") || strings.HasPrefix(l, "https://")) && !strings.Contains(l, "gleif.org") && !strings.ContainsAny(s, " \n") {
+			out = AppendUniq(out, s)
+		}
+	}
+	return out
+}
+
+// gleifJurisdiction turns "gb" into "GB" and "us_de" into "US-DE".
+func gleifJurisdiction(j string) string {
+	return strings.Replace(strings.ToUpper(strings.TrimSpace(j)), "_", "-", 1)
+}
+
+func (g *GLEIF) get(ctx context.Context, path string, v interface{}) error {
+	client := g.Client
+	if client == nil {
+		client = HTTPClient()
+	}
+	base := g.BaseURL
+	if base == "" {
+		base = GLEIFURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.api+json")
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	switch r.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(r.Body).Decode(v)
+	case http.StatusNotFound:
+		return ErrCompanyNotFound
+	}
+	return fmt.Errorf("gleif returned %s for %s", r.Status, path)
+}
@@ -0,0 +1,157 @@
+package util
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CompanyRecord is the free-form input we accept when there's no registry to ask, only Name is required.
+type CompanyRecord struct {
+	Name         string   `json:"name"`
+	Number       string   `json:"number"`
+	Jurisdiction string   `json:"jurisdiction"`
+	Address      string   `json:"address"`
+	Locality     string   `json:"locality"`
+	Postcode     string   `json:"postcode"`
+	Country      string   `json:"country"`
+	Officers     []string `json:"officers"`
+	WikipediaID  string   `json:"wikipedia_id"`
+}
+
+// Company turns the record into the same thing a CompanyProvider would have given us.
+func (r *CompanyRecord) Company() *Company {
+	c := &Company{
+		Name:             strings.TrimSpace(r.Name),
+		CompanyNumber:    strings.TrimSpace(r.Number),
+		JurisdictionCode: strings.ToLower(strings.TrimSpace(r.Jurisdiction)),
+		WikipediaID:      strings.TrimSpace(r.WikipediaID),
+		RetrievedAt:      time.Now(),
+	}
+	if c.JurisdictionCode == "" && strings.TrimSpace(r.Country) == "" {
+		// same assumption ParseCompanyID makes.
+		c.JurisdictionCode = "gb"
+	}
+	c.Source.Publisher = "input"
+	c.RegisteredAddress = Address{
+		StreetAddress: strings.TrimSpace(r.Address),
+		Locality:      strings.TrimSpace(r.Locality),
+		PostalCode:    strings.TrimSpace(r.Postcode),
+		Country:       strings.TrimSpace(r.Country),
+	}
+	c.RegisteredAddressInFull = joinNonEmpty([]string{r.Address, r.Locality, r.Postcode, r.Country}, ", ")
+	for _, name := range r.Officers {
+		if name = strings.TrimSpace(name); name != "" {
+			var entry OfficerEntry
+			entry.Officer.Name = name
+			c.Officers = append(c.Officers, entry)
+		}
+	}
+	c.BuildBag()
+	return c
+}
+
+// ReadCompanyRecords reads "json" (an object, an array of objects or one object per line) or "csv"
+// (with a header row naming the CompanyRecord fields, officers separated by ';').
+func ReadCompanyRecords(r io.Reader, format string) ([]*CompanyRecord, error) {
+	switch strings.ToLower(format) {
+	case "json", "jsonl":
+		return readJSONRecords(r)
+	case "csv":
+		return readCSVRecords(r)
+	}
+	return nil, fmt.Errorf("unknown input format '%s'", format)
+}
+
+func readJSONRecords(r io.Reader) ([]*CompanyRecord, error) {
+	br := bufio.NewReader(r)
+	var records []*CompanyRecord
+	for {
+		first, err := peekNonSpace(br)
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(br)
+		if first == '[' {
+			var batch []*CompanyRecord
+			if err := dec.Decode(&batch); err != nil {
+				return nil, err
+			}
+			records = append(records, batch...)
+		} else {
+			var rec CompanyRecord
+			if err := dec.Decode(&rec); err != nil {
+				return nil, err
+			}
+			records = append(records, &rec)
+		}
+		// the decoder buffers ahead, carry on from whatever it didn't use.
+		br = bufio.NewReader(io.MultiReader(dec.Buffered(), br))
+	}
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if strings.IndexByte(" \t\r\n", b) < 0 {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func readCSVRecords(r io.Reader) ([]*CompanyRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["name"]; !ok {
+		return nil, fmt.Errorf("csv input needs a name column")
+	}
+	var records []*CompanyRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		get := func(col string) string {
+			if i, ok := cols[col]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		rec := &CompanyRecord{
+			Name:         get("name"),
+			Number:       get("number"),
+			Jurisdiction: get("jurisdiction"),
+			Address:      get("address"),
+			Locality:     get("locality"),
+			Postcode:     get("postcode"),
+			Country:      get("country"),
+			WikipediaID:  get("wikipedia_id"),
+		}
+		if officers := get("officers"); officers != "" {
+			rec.Officers = strings.Split(officers, ";")
+		}
+		if rec.Name != "" {
+			records = append(records, rec)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCompanyRecords(t *testing.T) {
+	acme := &CompanyRecord{Name: "Acme Widgets Ltd", Number: "01234567", Jurisdiction: "gb", Locality: "Sheffield"}
+	for _, tt := range []struct {
+		name   string
+		format string
+		input  string
+		want   []*CompanyRecord
+		err    bool
+	}{
+		{name: "csv header in any order and case", format: "csv",
+			input: " Locality ,NAME,Number,jurisdiction,notes\nSheffield,Acme Widgets Ltd,01234567,gb,ignored\n",
+			want:  []*CompanyRecord{acme}},
+		{name: "csv every column", format: "CSV",
+			input: "name,number,jurisdiction,address,locality,postcode,country,officers,wikipedia_id\n" +
+				`"Smith, Jones & Co",SC123456,GB,"1 Mill Road, Unit 2",Glasgow,G1 1AA,United Kingdom,Jane Smith; Bob Jones,Q42` + "\n",
+			want: []*CompanyRecord{{Name: "Smith, Jones & Co", Number: "SC123456", Jurisdiction: "GB", Address: "1 Mill Road, Unit 2",
+				Locality: "Glasgow", Postcode: "G1 1AA", Country: "United Kingdom", Officers: []string{"Jane Smith", " Bob Jones"}, WikipediaID: "Q42"}}},
+		{name: "csv name only", format: "csv", input: "name\nAcme\n  \nWidget World\n",
+			want: []*CompanyRecord{{Name: "Acme"}, {Name: "Widget World"}}},
+		{name: "csv short and long rows", format: "csv", input: "name,number,locality\nAcme\nWidget World,09876543,Columbus,extra\n",
+			want: []*CompanyRecord{{Name: "Acme"}, {Name: "Widget World", Number: "09876543", Locality: "Columbus"}}},
+		{name: "csv rows without a name are skipped", format: "csv", input: "number,name\n01234567,\n09876543,Widget World\n",
+			want: []*CompanyRecord{{Name: "Widget World", Number: "09876543"}}},
+		{name: "csv without a name column", format: "csv", input: "company,number\nAcme,01234567\n", err: true},
+		{name: "csv unterminated quote", format: "csv", input: "name,number\n\"Acme,01234567\n", err: true},
+		{name: "csv empty", format: "csv", input: "", err: true},
+		{name: "json object", format: "json",
+			input: `{"name":"Acme Widgets Ltd","number":"01234567","jurisdiction":"gb","locality":"Sheffield"}`,
+			want:  []*CompanyRecord{acme}},
+		{name: "json array", format: "json", input: ` [{"name":"Acme"}, {"name":"Widget World","officers":["Jane Smith"]}]`,
+			want: []*CompanyRecord{{Name: "Acme"}, {Name: "Widget World", Officers: []string{"Jane Smith"}}}},
+		{name: "json lines", format: "jsonl", input: "{\"name\":\"Acme\"}\n\n{\"name\":\"Widget World\"}\n[{\"name\":\"Both\"}]\n",
+			want: []*CompanyRecord{{Name: "Acme"}, {Name: "Widget World"}, {Name: "Both"}}},
+		{name: "json empty", format: "json", input: " \n"},
+		{name: "json malformed", format: "json", input: `{"name":"Acme"`, err: true},
+		{name: "json wrong type", format: "json", input: `{"name":["Acme"]}`, err: true},
+		{name: "unknown format", format: "xml", input: "<name>Acme</name>", err: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCompanyRecords(strings.NewReader(tt.input), tt.format)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want an error: %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompanyRecordCompany(t *testing.T) {
+	c := (&CompanyRecord{Name: " Acme Widgets Ltd ", Address: "1 Forge Lane", Postcode: "S1 2AB", Officers: []string{" Jane Smith", ""}}).Company()
+	if c.Name != "Acme Widgets Ltd" || c.JurisdictionCode != "gb" || c.RegisteredAddressInFull != "1 Forge Lane, S1 2AB" {
+		t.Errorf("got %+v", c)
+	}
+	if len(c.Officers) != 1 || c.Officers[0].Officer.Name != "Jane Smith" {
+		t.Errorf("got officers %+v", c.Officers)
+	}
+	// a country and no jurisdiction isn't assumed to be gb.
+	if c := (&CompanyRecord{Name: "Widget World", Country: "United States"}).Company(); c.JurisdictionCode != "" {
+		t.Errorf("got jurisdiction %q", c.JurisdictionCode)
+	}
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	scraper "github.com/byung82/go-cloudflare-scraper"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const OpenCorporatesURL = "https://api.opencorporates.com"
+
+type OpenCorporates struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewOpenCorporates() *OpenCorporates {
+	return &OpenCorporates{BaseURL: OpenCorporatesURL}
+}
+
+func (oc *OpenCorporates) Name() string {
+	return "OpenCorporates"
+}
+
+// client lazily builds the cloudflare-scraping client unless one was supplied.
+func (oc *OpenCorporates) client() (*http.Client, error) {
+	if oc.Client != nil {
+		return oc.Client, nil
+	}
+	t, err := scraper.NewTransport(http.DefaultTransport)
+	if err != nil {
+		return nil, err
+	}
+	oc.Client = &http.Client{Transport: WrapTransport(t)}
+	return oc.Client, nil
+}
+
+func (oc *OpenCorporates) Lookup(ctx context.Context, jurisdiction, number string) (*Company, error) {
+	c, err := oc.client()
+	if err != nil {
+		return nil, err
+	}
+	uri := strings.TrimRight(oc.BaseURL, "/") + "/companies/" + jurisdiction + "/" + number
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.Do(req)
+	if err != nil {
+		logrus.WithError(err).Errorf("error getting '%s' from opencorporates", number)
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNotFound {
+		return nil, ErrCompanyNotFound
+	} else if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opencorporates returned %s", r.Status)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var res *OCResult
+	if err = json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	res.Results.Company.Bag = JsonIterator(body)
+	return &res.Results.Company, nil
+}
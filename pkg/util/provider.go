@@ -0,0 +1,65 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	ErrCompanyNotFound         = errors.New("company not found")
+	ErrUnsupportedJurisdiction = errors.New("jurisdiction not supported by provider")
+)
+
+// CompanyProvider is anything that can turn a registry identifier into a Company.
+type CompanyProvider interface {
+	Name() string
+	Lookup(ctx context.Context, jurisdiction, number string) (*Company, error)
+}
+
+// NameFinder is a provider that can also look companies up by name, the bulk snapshot can.
+type NameFinder interface {
+	FindByName(name string) []*Company
+}
+
+// Provider is used by GetCompanyKeywords, swap it out to change where company data comes from.
+var Provider CompanyProvider = NewOpenCorporates()
+
+// NewCompanyProvider returns the provider registered under name.
+func NewCompanyProvider(name string) (CompanyProvider, error) {
+	switch strings.ToLower(name) {
+	case "", "opencorporates", "oc":
+		return NewOpenCorporates(), nil
+	case "companieshouse", "ch":
+		key := os.Getenv("COMPANIES_HOUSE_API_KEY")
+		if key == "" {
+			return nil, errors.New("COMPANIES_HOUSE_API_KEY must be set to use companies house")
+		}
+		return NewCompaniesHouse(key), nil
+	case "gleif", "lei":
+		return NewGLEIF(), nil
+	case "bulk", "basiccompanydata":
+		files := os.Getenv("COMPANIES_HOUSE_BULK_DATA")
+		if files == "" {
+			return nil, errors.New("COMPANIES_HOUSE_BULK_DATA must point at the BasicCompanyData csv or zip files")
+		}
+		return LoadBulkIndex(strings.Split(files, ",")...)
+	}
+	return nil, fmt.Errorf("unknown company provider '%s'", name)
+}
+
+// ParseCompanyID splits "jurisdiction/number" (e.g. "ie/123456", "us_de/5678"), bare numbers are assumed to be gb.
+func ParseCompanyID(id string) (jurisdiction, number string) {
+	id = strings.TrimSpace(id)
+	if i := strings.Index(id, "/"); i > 0 {
+		return strings.ToLower(id[:i]), strings.TrimSpace(id[i+1:])
+	}
+	return "gb", id
+}
+
+func GetCompanyKeywords(cid string) (*Company, error) {
+	jurisdiction, number := ParseCompanyID(cid)
+	return Provider.Lookup(context.Background(), jurisdiction, number)
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"github.com/levigross/grequests"
+	"net/http"
+	"sync"
+)
+
+var (
+	middlewareLock sync.RWMutex
+	middleware     []func(http.RoundTripper) http.RoundTripper
+)
+
+// Use registers a wrapper (a cache, a recorder...) applied to every transport handed out by WrapTransport.
+// Register before building providers, sources or crawlers, anything created earlier won't see it.
+func Use(mw func(http.RoundTripper) http.RoundTripper) {
+	middlewareLock.Lock()
+	defer middlewareLock.Unlock()
+	middleware = append(middleware, mw)
+}
+
+// ResetMiddleware forgets everything registered with Use, so a test can put back the transports it found.
+func ResetMiddleware() {
+	middlewareLock.Lock()
+	defer middlewareLock.Unlock()
+	middleware = nil
+}
+
+// WrapTransport applies the registered middleware to rt, the first registered ends up outermost.
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	middlewareLock.RLock()
+	defer middlewareLock.RUnlock()
+	for i := len(middleware) - 1; i >= 0; i-- {
+		rt = middleware[i](rt)
+	}
+	return rt
+}
+
+// HTTPClient is what registry clients and sources should be making requests with.
+func HTTPClient() *http.Client {
+	return &http.Client{Transport: WrapTransport(http.DefaultTransport)}
+}
+
+func GetProxyRequestOptions() *grequests.RequestOptions {
+	return &grequests.RequestOptions{HTTPClient: HTTPClient()}
+}
+
+// REDACTED
+//	return &grequests.RequestOptions{
+//		Proxies: map[string]*url.URL{
+//			"http":  proxyURL,
+//			"https": proxyURL,
+//		},
+//		//UserAgent:
+//	}
+//}
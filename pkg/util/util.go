@@ -3,12 +3,7 @@ package util
 import (
 	"encoding/json"
 	"github.com/PaesslerAG/jsonpath"
-	scraper "github.com/byung82/go-cloudflare-scraper"
-	"github.com/levigross/grequests"
 	"github.com/sirupsen/logrus"
-	"io/ioutil"
-	"log"
-	"net/http"
 	"strings"
 	"time"
 )
@@ -35,73 +30,101 @@ type Company struct {
 		Terms       string    `json:"terms"`
 		RetrievedAt time.Time `json:"retrieved_at"`
 	} `json:"source"`
-	AgentName                      interface{}   `json:"agent_name"`
-	AgentAddress                   interface{}   `json:"agent_address"`
-	AlternativeNames               []interface{} `json:"alternative_names"`
-	PreviousNames                  []interface{} `json:"previous_names"`
-	NumberOfEmployees              interface{}   `json:"number_of_employees"`
-	NativeCompanyNumber            interface{}   `json:"native_company_number"`
-	AlternateRegistrationEntities  []interface{} `json:"alternate_registration_entities"`
-	PreviousRegistrationEntities   []interface{} `json:"previous_registration_entities"`
-	SubsequentRegistrationEntities []interface{} `json:"subsequent_registration_entities"`
-	RegisteredAddressInFull        string        `json:"registered_address_in_full"`
-	IndustryCodes                  []struct {
-		IndustryCode struct {
-			Code           string `json:"code"`
-			Description    string `json:"description"`
-			CodeSchemeID   string `json:"code_scheme_id"`
-			CodeSchemeName string `json:"code_scheme_name"`
-			UID            string `json:"uid"`
-		} `json:"industry_code"`
-	} `json:"industry_codes"`
-	Identifiers            []interface{} `json:"identifiers"`
-	TrademarkRegistrations []interface{} `json:"trademark_registrations"`
-	RegisteredAddress      struct {
-		StreetAddress string `json:"street_address"`
-		Locality      string `json:"locality"`
-		Region        string `json:"region"`
-		PostalCode    string `json:"postal_code"`
-		Country       string `json:"country"`
-	} `json:"registered_address"`
-	CorporateGroupings       []interface{} `json:"corporate_groupings"`
-	Data                     interface{}   `json:"data"`
-	FinancialSummary         interface{}   `json:"financial_summary"`
-	HomeCompany              interface{}   `json:"home_company"`
-	ControllingEntity        interface{}   `json:"controlling_entity"`
-	UltimateBeneficialOwners []struct {
+	AgentName                      interface{}         `json:"agent_name"`
+	AgentAddress                   interface{}         `json:"agent_address"`
+	AlternativeNames               []interface{}       `json:"alternative_names"`
+	PreviousNames                  []interface{}       `json:"previous_names"`
+	NumberOfEmployees              interface{}         `json:"number_of_employees"`
+	NativeCompanyNumber            interface{}         `json:"native_company_number"`
+	AlternateRegistrationEntities  []interface{}       `json:"alternate_registration_entities"`
+	PreviousRegistrationEntities   []interface{}       `json:"previous_registration_entities"`
+	SubsequentRegistrationEntities []interface{}       `json:"subsequent_registration_entities"`
+	RegisteredAddressInFull        string              `json:"registered_address_in_full"`
+	IndustryCodes                  []IndustryCodeEntry `json:"industry_codes"`
+	Identifiers                    []interface{}       `json:"identifiers"`
+	WikipediaID                    string              `json:"wikipedia_id"`
+	LEI                            string              `json:"lei"`
+	LEIRelationships               []LEIRelationship   `json:"lei_relationships"`
+	Websites                       []string            `json:"websites"` // declared somewhere official, e.g. an LEI record
+	TrademarkRegistrations         []interface{}       `json:"trademark_registrations"`
+	RegisteredAddress              Address             `json:"registered_address"`
+	CorporateGroupings             []interface{}       `json:"corporate_groupings"`
+	Data                           interface{}         `json:"data"`
+	FinancialSummary               interface{}         `json:"financial_summary"`
+	HomeCompany                    interface{}         `json:"home_company"`
+	ControllingEntity              interface{}         `json:"controlling_entity"`
+	UltimateBeneficialOwners       []struct {
 		UltimateBeneficialOwner struct {
 			Name              string `json:"name"`
 			OpencorporatesURL string `json:"opencorporates_url"`
 		} `json:"ultimate_beneficial_owner"`
 	} `json:"ultimate_beneficial_owners"`
-	Filings []struct {
-		Filing struct {
-			ID                int         `json:"id"`
-			Title             string      `json:"title"`
-			Description       string      `json:"description"`
-			UID               string      `json:"uid"`
-			FilingTypeCode    string      `json:"filing_type_code"`
-			FilingTypeName    string      `json:"filing_type_name"`
-			URL               interface{} `json:"url"`
-			OpencorporatesURL string      `json:"opencorporates_url"`
-			Date              string      `json:"date"`
-		} `json:"filing"`
-	} `json:"filings"`
-	Officers []struct {
-		Officer struct {
-			ID                int         `json:"id"`
-			Name              string      `json:"name"`
-			Position          string      `json:"position"`
-			UID               interface{} `json:"uid"`
-			StartDate         string      `json:"start_date"`
-			EndDate           interface{} `json:"end_date"`
-			OpencorporatesURL string      `json:"opencorporates_url"`
-			Occupation        string      `json:"occupation"`
-			Inactive          bool        `json:"inactive"`
-			CurrentStatus     interface{} `json:"current_status"`
-		} `json:"officer"`
-	} `json:"officers"`
-	Bag string
+	Filings  []FilingEntry  `json:"filings"`
+	Officers []OfficerEntry `json:"officers"`
+	Bag      string
+}
+
+type Address struct {
+	StreetAddress string `json:"street_address"`
+	Locality      string `json:"locality"`
+	Region        string `json:"region"`
+	PostalCode    string `json:"postal_code"`
+	Country       string `json:"country"`
+}
+
+type IndustryCode struct {
+	Code           string `json:"code"`
+	Description    string `json:"description"`
+	CodeSchemeID   string `json:"code_scheme_id"`
+	CodeSchemeName string `json:"code_scheme_name"`
+	UID            string `json:"uid"`
+}
+
+type IndustryCodeEntry struct {
+	IndustryCode IndustryCode `json:"industry_code"`
+}
+
+type Filing struct {
+	ID                int         `json:"id"`
+	Title             string      `json:"title"`
+	Description       string      `json:"description"`
+	UID               string      `json:"uid"`
+	FilingTypeCode    string      `json:"filing_type_code"`
+	FilingTypeName    string      `json:"filing_type_name"`
+	URL               interface{} `json:"url"`
+	OpencorporatesURL string      `json:"opencorporates_url"`
+	Date              string      `json:"date"`
+}
+
+type FilingEntry struct {
+	Filing Filing `json:"filing"`
+}
+
+type Officer struct {
+	ID                int         `json:"id"`
+	Name              string      `json:"name"`
+	Position          string      `json:"position"`
+	UID               interface{} `json:"uid"`
+	StartDate         string      `json:"start_date"`
+	EndDate           interface{} `json:"end_date"`
+	OpencorporatesURL string      `json:"opencorporates_url"`
+	Occupation        string      `json:"occupation"`
+	Inactive          bool        `json:"inactive"`
+	CurrentStatus     interface{} `json:"current_status"`
+}
+
+type OfficerEntry struct {
+	Officer Officer `json:"officer"`
+}
+
+// BuildBag fills Bag from the company's own fields, for providers that don't hand us opencorporates json.
+func (c *Company) BuildBag() {
+	b, err := json.Marshal(map[string]interface{}{"company": c})
+	if err != nil {
+		logrus.WithError(err).Errorf("error encoding company")
+		return
+	}
+	c.Bag = JsonIterator(b)
 }
 
 type OCResult struct {
@@ -130,47 +153,7 @@ var keys = map[string]int{
 	"region":         0,
 	"postal_code":    0,
 	"country":        0,
-	"industry_codes":        0,
-}
-
-func GetProxyRequestOptions() *grequests.RequestOptions {
-	return nil
-}
-
-// REDACTED
-//	return &grequests.RequestOptions{
-//		Proxies: map[string]*url.URL{
-//			"http":  proxyURL,
-//			"https": proxyURL,
-//		},
-//		//UserAgent:
-//	}
-//}
-
-// This is very bad and shouldn't be allowed to continue.
-func GetCompanyKeywords(cid string) (*Company, error) {
-	scraper, err := scraper.NewTransport(http.DefaultTransport)
-	if err != nil {
-		log.Fatal(err)
-	}
-	//
-	c := http.Client{Transport: scraper}
-
-	//r, err := grequests.Get("https://api.opencorporates.com/companies/gb/"+cid, GetProxyRequestOptions())
-	r, err := c.Get("https://api.opencorporates.com/companies/gb/"+cid)
-	if err != nil {
-		logrus.WithError(err).Errorf("error getting '%s' from opencorporates", cid)
-		return nil, err
-	}
-	var res *OCResult
-	body, err := ioutil.ReadAll(r.Body)
-	//println(string(body))
-	if err = json.Unmarshal(body, &res); err == nil {
-		res.Results.Company.Bag = JsonIterator(body)
-		//logrus.WithField("company_id", cid).Infof("retrieved '%s' (%q) from opencorporates", res.Results.Company.Name, res.Results.Company.Bag)
-		return &res.Results.Company, nil
-	}
-	return nil, err
+	"industry_codes": 0,
 }
 
 func JsonIterator(j []byte) string {
@@ -208,3 +191,39 @@ func AppendUniq(slice []string, i string) []string {
 	}
 	return append(slice, i)
 }
+
+// PreviousNameList pulls the names out of PreviousNames, whichever provider filled it in.
+func (c *Company) PreviousNameList() []string {
+	var names []string
+	for _, pn := range c.PreviousNames {
+		switch v := pn.(type) {
+		case string:
+			names = AppendUniq(names, v)
+		case map[string]interface{}:
+			if name, ok := v["company_name"].(string); ok && name != "" {
+				names = AppendUniq(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Identifier finds the uid of an opencorporates style identifier ({"identifier": {"identifier_system_code": "lei",
+// "uid": ...}}) by its system code, "" if there isn't one.
+func (c *Company) Identifier(system string) string {
+	for _, id := range c.Identifiers {
+		v, ok := id.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if inner, ok := v["identifier"].(map[string]interface{}); ok {
+			v = inner
+		}
+		if code, _ := v["identifier_system_code"].(string); strings.EqualFold(code, system) {
+			if uid, _ := v["uid"].(string); uid != "" {
+				return uid
+			}
+		}
+	}
+	return ""
+}